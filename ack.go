@@ -0,0 +1,76 @@
+package logopher
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// ErrUnexpectedAck is returned when a stream writer waiting for its Ack reads
+// something else from the connection
+var ErrUnexpectedAck = errors.New("logopher: unexpected acknowledgement")
+
+// awaitsAck reports whether each write must be acknowledged before it counts as
+// delivered. Datagrams have no response channel to wait on.
+func (u *baseWriter) awaitsAck() bool {
+	return len(u.Ack) > 0 && !u.datagram
+}
+
+// awaitAcks reads one Ack for each message in the payload just written, which
+// a BatchWriter may have combined, and checks every one is the Ack expected.
+// The caller must hold the mutex.
+func (u *baseWriter) awaitAcks(ctx context.Context, payload []byte) error {
+	count := 1
+	if len(u.Terminator) > 0 {
+		if n := bytes.Count(payload, u.Terminator); n > count {
+			count = n
+		}
+	}
+	timeout := u.AckTimeout
+	if timeout <= 0 {
+		timeout = DefaultAckTimeout
+	}
+	deadline := time.Now().Add(timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	socket := u.socket
+	socket.SetReadDeadline(deadline)
+	defer socket.SetReadDeadline(time.Time{})
+	// Give up on the read, rather than the whole timeout, if ctx is canceled
+	defer context.AfterFunc(ctx, func() {
+		socket.SetReadDeadline(aLongTimeAgo)
+	})()
+
+	ack := make([]byte, len(u.Ack))
+	for i := 0; i < count; i++ {
+		if _, err := io.ReadFull(socket, ack); err != nil {
+			return err
+		}
+		if !bytes.Equal(ack, u.Ack) {
+			return fmt.Errorf("%w %q", ErrUnexpectedAck, ack)
+		}
+	}
+	return nil
+}
+
+// unacknowledged handles a write whose acknowledgement never came, or was
+// wrong. The payload may or may not have been received, and whatever is read
+// next can't be trusted to answer the next write, so the connection is
+// replaced. The caller must hold the mutex.
+func (u *baseWriter) unacknowledged(ctx context.Context, cause error) error {
+	u.stats.writeErrors.Add(1)
+	u.logf("No acknowledgement from %s for a write. Underlying error: %s", u.address, cause)
+	u.lastErr = fmt.Errorf("logopher: write was not acknowledged: %w", cause)
+	u.close()
+	if err := u.reconnect(cause); err != nil {
+		u.logf("Failed to reconnect to %s after a write went unacknowledged. Underlying error: %s", u.address, err)
+	}
+	if ctxErr := contextError(ctx); ctxErr != nil {
+		return ctxErr
+	}
+	return u.lastErr
+}
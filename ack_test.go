@@ -0,0 +1,153 @@
+package logopher
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+// listenAcking opens a local TCP listener which reads lines, and answers each
+// one it is allowed to with ack. Every line received is sent down the returned
+// channel, and one answer is sent for each value on release.
+func listenAcking(t *testing.T, ack string) (net.Listener, <-chan string, chan<- struct{}) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	lines := make(chan string, 100)
+	release := make(chan struct{}, 100)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					lines <- line
+					<-release
+					if _, err := conn.Write([]byte(ack)); err != nil {
+						return
+					}
+				}
+			}()
+		}
+	}()
+	return l, lines, release
+}
+
+func TestTCPWriterWaitsForAck(t *testing.T) {
+	l, lines, release := listenAcking(t, "ACK\n")
+	w, err := DialTCP(l.Addr().String(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	WithAck([]byte("ACK\n"), 5*time.Second)(&w.baseWriter)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := w.Write([]byte("hello\n"))
+		done <- err
+	}()
+	<-lines
+	select {
+	case err := <-done:
+		t.Fatalf("Expected Write to wait for the ack, but it returned %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	release <- struct{}{}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Expected no error once acknowledged, got %s", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected Write to return once the ack arrived")
+	}
+	if written := w.Stats().MessagesWritten; written != 1 {
+		t.Errorf("Expected 1 message written, got %d", written)
+	}
+}
+
+func TestTCPWriterAckPerMessage(t *testing.T) {
+	l, lines, release := listenAcking(t, "ok")
+	w, err := DialTCP(l.Addr().String(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	w.Ack = []byte("ok")
+	for i := 0; i < 3; i++ {
+		release <- struct{}{}
+	}
+
+	if _, err := w.Write([]byte("one\ntwo\nthree\n")); err != nil {
+		t.Fatalf("Expected every message in the payload to be acknowledged, got %s", err)
+	}
+	for i := 0; i < 3; i++ {
+		<-lines
+	}
+}
+
+func TestTCPWriterAckTimeout(t *testing.T) {
+	l, lines, _ := listenAcking(t, "ACK\n")
+	w, err := DialTCP(l.Addr().String(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	WithAck([]byte("ACK\n"), 50*time.Millisecond)(&w.baseWriter)
+
+	_, err = w.Write([]byte("hello\n"))
+	if !isTimeout(err) {
+		t.Fatalf("Expected the missing ack to time out, got %v", err)
+	}
+	<-lines
+	stats := w.Stats()
+	if stats.MessagesWritten != 0 || stats.WriteErrors != 1 {
+		t.Errorf("Expected the write to count as an error, got %+v", stats)
+	}
+	if stats.Reconnects != 1 {
+		t.Errorf("Expected the connection to be replaced, got %d reconnects", stats.Reconnects)
+	}
+}
+
+func TestTCPWriterUnexpectedAck(t *testing.T) {
+	l, _, release := listenAcking(t, "NAK\n")
+	w, err := DialTCP(l.Addr().String(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	w.Ack = []byte("ACK\n")
+	release <- struct{}{}
+
+	if _, err := w.Write([]byte("hello\n")); !errors.Is(err, ErrUnexpectedAck) {
+		t.Fatalf("Expected ErrUnexpectedAck, got %v", err)
+	}
+	if !errors.Is(w.LastError(), ErrUnexpectedAck) {
+		t.Errorf("Expected the last error to be ErrUnexpectedAck, got %v", w.LastError())
+	}
+}
+
+func TestUDPWriterIgnoresAck(t *testing.T) {
+	conn := &fakeConn{}
+	w, _ := newFakeWriter(t, conn)
+	w.datagram = true
+	w.Ack = []byte("ACK\n")
+
+	if _, err := w.Write([]byte("hello\n")); err != nil {
+		t.Fatalf("Expected a datagram not to wait for an ack, got %s", err)
+	}
+}
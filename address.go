@@ -0,0 +1,67 @@
+package logopher
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// DefaultPort is used when an address doesn't include one. It is the default
+// port of LogStash's udp and tcp inputs.
+const DefaultPort = "5000"
+
+// normalizeAddress checks that address is a usable host:port, adding
+// DefaultPort if the port is missing, so that a bad address fails with a
+// descriptive error before anything is dialed
+func normalizeAddress(address string) (string, error) {
+	address = strings.TrimSpace(address)
+	if address == "" {
+		return "", errors.New("logopher: address is empty")
+	}
+
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		var addrErr *net.AddrError
+		switch {
+		case net.ParseIP(address) != nil:
+			// A bare IPv6 address, whose colons confuse SplitHostPort
+			host, port = address, DefaultPort
+		case errors.As(err, &addrErr) && addrErr.Err == "missing port in address":
+			host, port = strings.TrimSuffix(strings.TrimPrefix(address, "["), "]"), DefaultPort
+		default:
+			return "", fmt.Errorf("logopher: invalid address %q: %w", address, err)
+		}
+	}
+
+	if !validHost(host) {
+		return "", fmt.Errorf("logopher: invalid address %q: bad host %q", address, host)
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return "", fmt.Errorf("logopher: invalid address %q: bad port %q", address, port)
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+// validHost reports whether host is an IP address or a syntactically valid
+// hostname. An empty host is allowed, and means the local system.
+func validHost(host string) bool {
+	if host == "" || net.ParseIP(host) != nil {
+		return true
+	}
+	if len(host) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(strings.TrimSuffix(host, "."), ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,69 @@
+package logopher
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNormalizeAddress(t *testing.T) {
+	valid := map[string]string{
+		"logstash:5044":      "logstash:5044",
+		"logstash":           "logstash:5000",
+		" logstash.local ":   "logstash.local:5000",
+		"10.0.0.1":           "10.0.0.1:5000",
+		"[::1]:5044":         "[::1]:5044",
+		"::1":                "[::1]:5000",
+		"[::1]":              "[::1]:5000",
+		":5000":              ":5000",
+		"log_stash-1.prod:1": "log_stash-1.prod:1",
+	}
+	for input, expected := range valid {
+		got, err := normalizeAddress(input)
+		if err != nil {
+			t.Errorf("%q: unexpected error %v", input, err)
+		} else if got != expected {
+			t.Errorf("%q: expected %q, got %q", input, expected, got)
+		}
+	}
+
+	invalid := []string{
+		"",
+		"   ",
+		"bad host:5000",
+		"-leading.dash:5000",
+		"logstash:port",
+		"logstash:0",
+		"logstash:70000",
+		"logstash:5000:5000",
+	}
+	for _, input := range invalid {
+		if _, err := normalizeAddress(input); err == nil {
+			t.Errorf("%q: expected an error", input)
+		} else if !strings.HasPrefix(err.Error(), "logopher: ") {
+			t.Errorf("%q: expected a descriptive error, got %v", input, err)
+		}
+	}
+}
+
+func TestDialRejectsBadAddress(t *testing.T) {
+	if _, err := DialUDP("", false); err == nil {
+		t.Error("Expected DialUDP to reject an empty address")
+	}
+	if _, err := DialTCP("bad host:5000", false); err == nil {
+		t.Error("Expected DialTCP to reject an unparseable host")
+	}
+	if _, err := DialTLS("logstash:port", nil, false); err == nil {
+		t.Error("Expected DialTLS to reject a bad port")
+	}
+}
+
+func TestDialUDPDefaultsPort(t *testing.T) {
+	w, err := DialUDP("127.0.0.1", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if w.address != "127.0.0.1:5000" {
+		t.Errorf("Expected the default port to be added, got %q", w.address)
+	}
+}
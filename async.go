@@ -0,0 +1,459 @@
+package logopher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrClosed is returned when logging through a writer which has been closed
+var ErrClosed = errors.New("logopher: writer is closed")
+
+var _ FieldLogger = (*AsyncWriter)(nil)
+
+// OverflowPolicy decides what an AsyncWriter does with a message when its queue
+// is full
+type OverflowPolicy int
+
+const (
+	// Block waits for the background goroutine to make room. Nothing is lost,
+	// but a slow network slows down the caller.
+	Block OverflowPolicy = iota
+	// DropNewest discards the message being logged
+	DropNewest
+	// DropOldest discards the message which has waited longest in the queue,
+	// to make room for the one being logged
+	DropOldest
+)
+
+// AsyncWriter wraps a Writer so that logging never waits on the network. Each
+// message is serialized immediately, so its @timestamp reflects when it was
+// logged, then queued for a background goroutine to write.
+//
+// What happens when the queue is full is decided by Policy. By default logging
+// blocks until the background goroutine makes room.
+//
+// Messages logged with a high priority, including those logged through
+// LogFields at LevelError, wait in a queue of their own, and are written ahead
+// of everything else. So that a steady stream of them can't hold up other
+// messages indefinitely, one other message is let through after every eight
+// high priority messages written in a row.
+type AsyncWriter struct {
+	writer  Writer
+	queue   chan []byte
+	urgent  chan []byte
+	flushes chan chan error
+	done    chan struct{}
+
+	// stopped is closed, after stopErr is set, if the context given to
+	// NewAsyncWriterContext ends
+	stopped chan struct{}
+	stopErr error
+	// shutting is closed once Shutdown begins, to turn away anyone blocked on a
+	// full queue, who would otherwise keep Shutdown from taking mu
+	shutting     chan struct{}
+	shuttingOnce sync.Once
+
+	// mu guards closed and shut, and keeps Close from closing the queue while a
+	// message is being enqueued
+	mu     sync.RWMutex
+	closed bool
+	shut   bool
+
+	dropped atomic.Uint64
+	// highWater is the most messages there have been in the queues at once
+	highWater atomic.Int64
+
+	// pending counts the messages queued but not yet written, and idle is
+	// closed each time it falls to zero, to wake anyone waiting in Drain
+	pendingMu sync.Mutex
+	pending   int
+	idle      chan struct{}
+
+	// Policy decides what happens to a message logged while the queue is full.
+	// Set it before logging begins.
+	Policy OverflowPolicy
+	// OnError, if set, is called from the background goroutine with any error
+	// returned while writing a queued message. Set it before logging begins.
+	OnError func(error)
+}
+
+// Priorities for LogWithPriority
+const (
+	PriorityNormal = 0
+	PriorityHigh   = 1
+)
+
+// maxUrgentStreak is how many high priority messages an AsyncWriter writes in a
+// row before letting a waiting normal one through
+const maxUrgentStreak = 8
+
+// NewAsyncWriter creates an AsyncWriter which queues up to bufferSize messages
+// in front of w, and as many again of high priority
+func NewAsyncWriter(w Writer, bufferSize int) *AsyncWriter {
+	return NewAsyncWriterContext(context.Background(), w, bufferSize)
+}
+
+// NewAsyncWriterContext is NewAsyncWriter, with a background goroutine which
+// also stops when ctx is done, such as when a parent component shuts down.
+// Anything still queued at that point is discarded, and logging from then on
+// fails with an error which matches both ErrClosed and the context's cause
+// under errors.Is. Close must still be called, to close w.
+func NewAsyncWriterContext(ctx context.Context, w Writer, bufferSize int) *AsyncWriter {
+	a := &AsyncWriter{
+		writer:   w,
+		queue:    make(chan []byte, bufferSize),
+		urgent:   make(chan []byte, bufferSize),
+		flushes:  make(chan chan error),
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+		shutting: make(chan struct{}),
+	}
+	go a.run(ctx)
+	return a
+}
+
+// run drains the queues until they are closed or ctx is done, answering any
+// Flush along the way
+func (a *AsyncWriter) run(ctx context.Context) {
+	defer close(a.done)
+	queue, urgent := a.queue, a.urgent
+	streak := 0
+	for queue != nil || urgent != nil {
+		// Check first, so a busy queue can't keep the goroutine from noticing
+		if ctx.Err() != nil {
+			a.stop(context.Cause(ctx))
+			return
+		}
+		if streak >= maxUrgentStreak {
+			streak = 0
+			if data, open, ready := receive(queue); ready {
+				if !open {
+					queue = nil
+				} else {
+					a.write(data)
+				}
+				continue
+			}
+		}
+		if data, open, ready := receive(urgent); ready {
+			if !open {
+				urgent = nil
+			} else {
+				a.write(data)
+				streak++
+			}
+			continue
+		}
+		streak = 0
+
+		select {
+		case <-ctx.Done():
+			a.stop(context.Cause(ctx))
+			return
+		case data, ok := <-urgent:
+			if !ok {
+				urgent = nil
+				continue
+			}
+			a.write(data)
+			streak++
+		case data, ok := <-queue:
+			if !ok {
+				queue = nil
+				continue
+			}
+			a.write(data)
+		case reply := <-a.flushes:
+			reply <- a.drain()
+		}
+	}
+}
+
+// receive takes a message from lane if one is ready, without waiting. ready is
+// false if there is none, and open is false if lane has been closed.
+func receive(lane chan []byte) (data []byte, open, ready bool) {
+	select {
+	case data, open = <-lane:
+		return data, open, true
+	default:
+		return nil, false, false
+	}
+}
+
+// write writes a queued message, reporting any error to OnError
+func (a *AsyncWriter) write(data []byte) {
+	if _, err := a.writer.Write(data); err != nil && a.OnError != nil {
+		a.OnError(err)
+	}
+	a.track(-1)
+}
+
+// track adds delta to the count of pending messages
+func (a *AsyncWriter) track(delta int) {
+	a.pendingMu.Lock()
+	defer a.pendingMu.Unlock()
+	if a.pending == 0 && delta > 0 {
+		a.idle = make(chan struct{})
+	}
+	a.pending += delta
+	if a.pending == 0 {
+		close(a.idle)
+	}
+}
+
+// stop refuses any further messages, because of cause
+func (a *AsyncWriter) stop(cause error) {
+	a.stopErr = fmt.Errorf("%w: %w", ErrClosed, cause)
+	// Wake anyone blocked on a full queue before taking the lock, since they
+	// hold it for reading while they wait
+	close(a.stopped)
+	a.mu.Lock()
+	a.closed = true
+	a.mu.Unlock()
+}
+
+// drain writes everything currently queued, high priority first, then flushes
+// the wrapped writer, and returns every error along the way
+func (a *AsyncWriter) drain() error {
+	var errs []error
+	for _, lane := range []chan []byte{a.urgent, a.queue} {
+		for {
+			data, open, ready := receive(lane)
+			if !ready || !open {
+				break
+			}
+			if _, err := a.writer.Write(data); err != nil {
+				errs = append(errs, err)
+			}
+			a.track(-1)
+		}
+	}
+	return errors.Join(append(errs, flushWriter(a.writer))...)
+}
+
+// Log queues msg to be written. The byte count returned is the size of the
+// queued payload.
+func (a *AsyncWriter) Log(msg string) (int, error) {
+	return a.LogFields(msg, nil)
+}
+
+// LogFields queues msg and fields to be written. The byte count returned is the
+// size of the queued payload. A message whose level field is LevelError, as
+// set by a leveled helper or the logrus hook, is queued with high priority.
+func (a *AsyncWriter) LogFields(msg string, fields map[string]interface{}) (int, error) {
+	data, err := a.encode(msg, fields)
+	if err != nil {
+		return 0, unlessSampledOut(err)
+	}
+	if fields["level"] == LevelError.String() {
+		return a.enqueue(a.urgent, data)
+	}
+	return a.enqueue(a.queue, data)
+}
+
+// LogWithPriority queues msg to be written, ahead of other messages if priority
+// is PriorityHigh or above
+func (a *AsyncWriter) LogWithPriority(priority int, msg string) (int, error) {
+	data, err := a.encode(msg, nil)
+	if err != nil {
+		return 0, unlessSampledOut(err)
+	}
+	if priority >= PriorityHigh {
+		return a.enqueue(a.urgent, data)
+	}
+	return a.enqueue(a.queue, data)
+}
+
+// Write queues a copy of rawBytes to be written as-is
+func (a *AsyncWriter) Write(rawBytes []byte) (int, error) {
+	return a.enqueue(a.queue, append([]byte(nil), rawBytes...))
+}
+
+// encode builds payloads the same way the wrapped writer would
+func (a *AsyncWriter) encode(msg string, fields map[string]interface{}) ([]byte, error) {
+	return encodeFor(a.writer, msg, fields)
+}
+
+// enqueue hands data to the background goroutine through lane, applying Policy
+// if it is full. A message dropped under DropNewest reports 0 bytes queued, and
+// no error.
+func (a *AsyncWriter) enqueue(lane chan []byte, data []byte) (int, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.closed {
+		if a.stopErr != nil {
+			return 0, a.stopErr
+		}
+		return 0, ErrClosed
+	}
+	select {
+	case <-a.shutting:
+		return 0, ErrClosed
+	default:
+	}
+
+	// Counted before it is queued, so the background goroutine can't write it
+	// and count it off first
+	a.track(1)
+	switch a.Policy {
+	case DropNewest:
+		select {
+		case lane <- data:
+		default:
+			a.track(-1)
+			a.dropped.Add(1)
+			return 0, nil
+		}
+	case DropOldest:
+		for queued := false; !queued; {
+			select {
+			case lane <- data:
+				queued = true
+			default:
+				// The background goroutine may beat us to the oldest message,
+				// in which case there's room already and nothing is dropped
+				select {
+				case <-lane:
+					a.track(-1)
+					a.dropped.Add(1)
+				default:
+				}
+			}
+		}
+	default:
+		select {
+		case lane <- data:
+		case <-a.stopped:
+			a.track(-1)
+			return 0, a.stopErr
+		case <-a.shutting:
+			a.track(-1)
+			return 0, ErrClosed
+		}
+	}
+	a.noteDepth()
+	return len(data), nil
+}
+
+// noteDepth raises the high water mark to the current depth of the queues, if
+// it is higher
+func (a *AsyncWriter) noteDepth() {
+	depth := int64(a.QueueLen())
+	for {
+		mark := a.highWater.Load()
+		if depth <= mark || a.highWater.CompareAndSwap(mark, depth) {
+			return
+		}
+	}
+}
+
+// QueueLen returns how many messages are waiting in the queues, not counting
+// one the background goroutine is writing
+func (a *AsyncWriter) QueueLen() int {
+	return len(a.queue) + len(a.urgent)
+}
+
+// QueueCap returns how many messages the queues can hold. It is twice the
+// buffer size the writer was created with, since high priority messages have a
+// queue of their own.
+func (a *AsyncWriter) QueueCap() int {
+	return cap(a.queue) + cap(a.urgent)
+}
+
+// HighWaterMark returns the most messages there have been waiting in the
+// queues at once, which shows how close they have come to filling up
+func (a *AsyncWriter) HighWaterMark() int {
+	return int(a.highWater.Load())
+}
+
+// DroppedCount returns how many messages have been discarded because the queue
+// was full
+func (a *AsyncWriter) DroppedCount() uint64 {
+	return a.dropped.Load()
+}
+
+// Flush waits until every message queued before it was called has been
+// written, then flushes the wrapped writer if it buffers too. Errors from the
+// messages written by Flush itself are returned, rather than passed to
+// OnError.
+func (a *AsyncWriter) Flush() error {
+	reply := make(chan error, 1)
+	select {
+	case a.flushes <- reply:
+		return <-reply
+	case <-a.done:
+		return ErrClosed
+	}
+}
+
+// Drain waits until every message queued so far has been written, without
+// stopping the writer or forcing anything along as Flush does, such as before
+// a health check. It returns early with the context's error if ctx is done
+// first, or an error matching ErrClosed if the writer stops with messages
+// still queued.
+func (a *AsyncWriter) Drain(ctx context.Context) error {
+	a.pendingMu.Lock()
+	if a.pending == 0 {
+		a.pendingMu.Unlock()
+		return nil
+	}
+	idle := a.idle
+	a.pendingMu.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-a.stopped:
+		return a.stopErr
+	}
+}
+
+// Reopen re-establishes the wrapped writer's connection. Queued messages are
+// kept, and written once the new connection is up.
+func (a *AsyncWriter) Reopen() error {
+	return a.writer.Reopen()
+}
+
+// Close stops accepting messages, waits for everything already queued to be
+// written, and then closes the wrapped writer. Calling it again does nothing,
+// and returns nil.
+func (a *AsyncWriter) Close() error {
+	return a.Shutdown(context.Background())
+}
+
+// Shutdown stops accepting messages, waits for everything already queued to be
+// written, and then closes the wrapped writer. If ctx is done before the queue
+// drains, the wrapped writer is closed anyway, so that shutdown can't hang, and
+// the context's error is returned alongside any error from closing. Logging
+// blocked on a full queue when Shutdown begins fails with ErrClosed.
+func (a *AsyncWriter) Shutdown(ctx context.Context) error {
+	// Wake anyone blocked on a full queue before taking the lock, since they
+	// hold it for reading while they wait
+	a.shuttingOnce.Do(func() { close(a.shutting) })
+	a.mu.Lock()
+	if a.shut {
+		a.mu.Unlock()
+		return nil
+	}
+	a.shut = true
+	if !a.closed {
+		// Once stopped by its context, the background goroutine has already
+		// exited, and the queue is left to be collected
+		a.closed = true
+		close(a.queue)
+		close(a.urgent)
+	}
+	a.mu.Unlock()
+
+	select {
+	case <-a.done:
+		return a.writer.Close()
+	case <-ctx.Done():
+		return errors.Join(ctx.Err(), a.writer.Close())
+	}
+}
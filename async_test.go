@@ -0,0 +1,448 @@
+package logopher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAsyncWriterFlushesOnClose(t *testing.T) {
+	gate := make(chan struct{})
+	r := &recordingWriter{gate: gate}
+	a := NewAsyncWriter(r, 10)
+
+	var expected []string
+	for i := 0; i < 5; i++ {
+		msg := fmt.Sprintf("message %d", i)
+		expected = append(expected, msg)
+		if _, err := a.Log(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(r.Payloads()) != 0 {
+		t.Error("Expected nothing to be written while the writer is stalled")
+	}
+
+	close(gate)
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := r.Messages(t); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+	if !r.closed {
+		t.Error("Expected the wrapped writer to be closed")
+	}
+	if _, err := a.Log("too late"); err != ErrClosed {
+		t.Errorf("Expected ErrClosed, got %v", err)
+	}
+}
+
+func TestAsyncWriterBlocksWhenFull(t *testing.T) {
+	gate := make(chan struct{})
+	r := &recordingWriter{gate: gate}
+	a := NewAsyncWriter(r, 2)
+	defer a.Close()
+
+	// The first message is picked up by the background goroutine, which then
+	// stalls on the gate. The next two fill the queue.
+	for i := 0; i < 3; i++ {
+		if _, err := a.Log("filler"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	logged := make(chan struct{})
+	go func() {
+		a.Log("blocked")
+		close(logged)
+	}()
+	select {
+	case <-logged:
+		t.Fatal("Expected Log to block while the queue is full")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	gate <- struct{}{}
+	select {
+	case <-logged:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected Log to unblock once there was room")
+	}
+	close(gate)
+}
+
+func TestAsyncWriterReportsErrors(t *testing.T) {
+	r := &recordingWriter{writeErr: fmt.Errorf("connection refused")}
+	a := NewAsyncWriter(r, 1)
+	errs := make(chan error, 1)
+	a.OnError = func(err error) { errs <- err }
+
+	if _, err := a.Log("doomed"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-errs:
+		if err != r.writeErr {
+			t.Errorf("Expected %v, got %v", r.writeErr, err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected OnError to be called")
+	}
+	a.Close()
+}
+
+// stallAsyncWriter logs one message, and waits for the background goroutine to
+// pick it up and stall on the gate, so that what follows fills the queue
+func stallAsyncWriter(t *testing.T, a *AsyncWriter, msg string) {
+	if _, err := a.Log(msg); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for len(a.queue) != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the background goroutine")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestAsyncWriterOverflowPolicies(t *testing.T) {
+	tests := []struct {
+		policy   OverflowPolicy
+		expected []string
+	}{
+		{DropNewest, []string{"m1", "m2", "m3"}},
+		{DropOldest, []string{"m1", "m4", "m5"}},
+	}
+	for _, test := range tests {
+		gate := make(chan struct{})
+		r := &recordingWriter{gate: gate}
+		a := NewAsyncWriter(r, 2)
+		a.Policy = test.policy
+
+		stallAsyncWriter(t, a, "m1")
+		for _, msg := range []string{"m2", "m3", "m4", "m5"} {
+			if _, err := a.Log(msg); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if dropped := a.DroppedCount(); dropped != 2 {
+			t.Errorf("Policy %d: expected 2 dropped, got %d", test.policy, dropped)
+		}
+
+		close(gate)
+		a.Close()
+		if got := r.Messages(t); !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("Policy %d: expected %v, got %v", test.policy, test.expected, got)
+		}
+	}
+}
+
+func TestAsyncWriterBlockPolicyDropsNothing(t *testing.T) {
+	r := &recordingWriter{}
+	a := NewAsyncWriter(r, 1)
+	for i := 0; i < 50; i++ {
+		a.Log(fmt.Sprintf("m%d", i))
+	}
+	a.Close()
+	if dropped := a.DroppedCount(); dropped != 0 {
+		t.Errorf("Expected nothing dropped, got %d", dropped)
+	}
+	if n := len(r.Payloads()); n != 50 {
+		t.Errorf("Expected 50 messages, got %d", n)
+	}
+}
+
+func TestAsyncWriterShutdown(t *testing.T) {
+	gate := make(chan struct{})
+	r := &recordingWriter{gate: gate}
+	a := NewAsyncWriter(r, 10)
+	for i := 0; i < 5; i++ {
+		a.Log(fmt.Sprintf("m%d", i))
+	}
+
+	// Let the writes trickle through while Shutdown waits
+	go func() {
+		for i := 0; i < 5; i++ {
+			time.Sleep(5 * time.Millisecond)
+			gate <- struct{}{}
+		}
+	}()
+	if err := a.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(r.Payloads()); n != 5 {
+		t.Errorf("Expected all 5 messages to be written before closing, got %d", n)
+	}
+	if !r.closed {
+		t.Error("Expected the wrapped writer to be closed")
+	}
+}
+
+func TestAsyncWriterShutdownDeadline(t *testing.T) {
+	gate := make(chan struct{})
+	defer close(gate)
+	r := &recordingWriter{gate: gate}
+	a := NewAsyncWriter(r, 10)
+	a.Log("stuck")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := a.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+	if !r.closed {
+		t.Error("Expected the wrapped writer to be closed anyway")
+	}
+}
+
+func TestAsyncWriterContextCancel(t *testing.T) {
+	r := &recordingWriter{}
+	ctx, cancel := context.WithCancelCause(context.Background())
+	a := NewAsyncWriterContext(ctx, r, 10)
+
+	if _, err := a.Log("before"); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	stopped := errors.New("parent shutting down")
+	cancel(stopped)
+	select {
+	case <-a.done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for the background goroutine to exit")
+	}
+
+	_, err := a.Log("after")
+	if !errors.Is(err, ErrClosed) || !errors.Is(err, stopped) {
+		t.Errorf("Expected an error matching ErrClosed and the cause, got %v", err)
+	}
+	if got := r.Messages(t); !reflect.DeepEqual(got, []string{"before"}) {
+		t.Errorf("Expected only the message logged before the cancel, got %v", got)
+	}
+
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !r.closed {
+		t.Error("Expected Close to still close the wrapped writer")
+	}
+}
+
+func TestAsyncWriterContextCancelUnblocksLoggers(t *testing.T) {
+	gate := make(chan struct{})
+	defer close(gate)
+	r := &recordingWriter{gate: gate}
+	ctx, cancel := context.WithCancel(context.Background())
+	a := NewAsyncWriterContext(ctx, r, 1)
+	defer a.Close()
+
+	stallAsyncWriter(t, a, "stalled")
+	a.Log("fills the queue")
+	logged := make(chan error)
+	go func() {
+		_, err := a.Log("blocked")
+		logged <- err
+	}()
+
+	cancel()
+	// The background goroutine only notices once its stalled write returns
+	gate <- struct{}{}
+	select {
+	case err := <-logged:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected the blocked Log to fail with context.Canceled, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the blocked Log to be released")
+	}
+}
+
+func TestAsyncWriterPriority(t *testing.T) {
+	gate := make(chan struct{})
+	r := &recordingWriter{gate: gate}
+	a := NewAsyncWriter(r, 100)
+	stallAsyncWriter(t, a, "stalled")
+
+	for i := 0; i < 50; i++ {
+		a.Log("spam")
+	}
+	a.LogWithPriority(PriorityHigh, "urgent")
+	a.LogFields("failed", map[string]interface{}{"level": LevelError.String()})
+	close(gate)
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	messages := r.Messages(t)
+	if len(messages) != 53 {
+		t.Fatalf("Expected 53 messages, got %d", len(messages))
+	}
+	// The stalled message was already being written
+	if messages[1] != "urgent" || messages[2] != "failed" {
+		t.Errorf("Expected the high priority messages to jump the queue, got %q", messages[:4])
+	}
+}
+
+func TestAsyncWriterPriorityDoesNotStarve(t *testing.T) {
+	gate := make(chan struct{})
+	r := &recordingWriter{gate: gate}
+	a := NewAsyncWriter(r, 100)
+	stallAsyncWriter(t, a, "stalled")
+
+	a.Log("patient")
+	for i := 0; i < 50; i++ {
+		a.LogWithPriority(PriorityHigh, "urgent")
+	}
+	close(gate)
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	messages := r.Messages(t)
+	for i, msg := range messages {
+		if msg == "patient" {
+			if i != 1+maxUrgentStreak {
+				t.Errorf("Expected the normal message after %d urgent ones, got it at %d", maxUrgentStreak, i)
+			}
+			return
+		}
+	}
+	t.Error("Expected the normal message to be written")
+}
+
+func TestAsyncWriterDrain(t *testing.T) {
+	gate := make(chan struct{})
+	r := &recordingWriter{gate: gate}
+	a := NewAsyncWriter(r, 10)
+	defer a.Close()
+
+	if err := a.Drain(context.Background()); err != nil {
+		t.Fatalf("Expected an empty writer to drain at once, got %s", err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := a.Log(fmt.Sprintf("message %d", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := a.Drain(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected Drain to give up with the context, got %v", err)
+	}
+
+	drained := make(chan error, 1)
+	go func() { drained <- a.Drain(context.Background()) }()
+	gate <- struct{}{}
+	gate <- struct{}{}
+	select {
+	case err := <-drained:
+		t.Fatalf("Expected Drain to wait for the last message, but it returned %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	gate <- struct{}{}
+	select {
+	case err := <-drained:
+		if err != nil {
+			t.Fatalf("Expected no error, got %s", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected Drain to return once everything was written")
+	}
+	if got := len(r.Payloads()); got != 3 {
+		t.Errorf("Expected 3 messages written once drained, got %d", got)
+	}
+	close(gate)
+
+	if _, err := a.Log("still open"); err != nil {
+		t.Errorf("Expected the writer to keep accepting messages after Drain, got %s", err)
+	}
+}
+
+func TestAsyncWriterQueueMetrics(t *testing.T) {
+	gate := make(chan struct{})
+	r := &recordingWriter{gate: gate}
+	a := NewAsyncWriter(r, 5)
+	defer a.Close()
+
+	if a.QueueCap() != 10 {
+		t.Errorf("Expected a capacity of 10, got %d", a.QueueCap())
+	}
+	stallAsyncWriter(t, a, "stalled")
+	for i := 0; i < 3; i++ {
+		a.Log("pending")
+	}
+	a.LogWithPriority(PriorityHigh, "urgent")
+	if a.QueueLen() != 4 {
+		t.Errorf("Expected 4 messages queued, got %d", a.QueueLen())
+	}
+	if a.HighWaterMark() != 4 {
+		t.Errorf("Expected a high water mark of 4, got %d", a.HighWaterMark())
+	}
+
+	close(gate)
+	if err := a.Drain(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if a.QueueLen() != 0 {
+		t.Errorf("Expected an empty queue once drained, got %d", a.QueueLen())
+	}
+	a.Log("after")
+	if a.HighWaterMark() != 4 {
+		t.Errorf("Expected the high water mark to stay at 4, got %d", a.HighWaterMark())
+	}
+}
+
+func TestAsyncWriterCloseTwice(t *testing.T) {
+	a := NewAsyncWriter(&recordingWriter{}, 1)
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Close(); err != nil {
+		t.Errorf("Expected a second Close to return nil, got %s", err)
+	}
+}
+
+func TestAsyncWriterShutdownUnblocksLoggers(t *testing.T) {
+	gate := make(chan struct{})
+	r := &recordingWriter{gate: gate}
+	a := NewAsyncWriter(r, 1)
+	defer close(gate)
+
+	stallAsyncWriter(t, a, "stalled")
+	a.Log("fills the queue")
+	blocked := make(chan error, 1)
+	go func() {
+		_, err := a.Log("blocked")
+		blocked <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	shut := make(chan error, 1)
+	go func() { shut <- a.Shutdown(ctx) }()
+	select {
+	case err := <-shut:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected the deadline to cut Shutdown short, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected Shutdown to honor its deadline while a logger was blocked")
+	}
+	select {
+	case err := <-blocked:
+		if err != ErrClosed {
+			t.Errorf("Expected the blocked Log to fail with ErrClosed, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the blocked Log to return once Shutdown began")
+	}
+}
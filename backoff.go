@@ -0,0 +1,94 @@
+package logopher
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+)
+
+// The default parameters for ReopenWithBackoff
+const (
+	DefaultBackoffInitial    = 100 * time.Millisecond
+	DefaultBackoffMax        = 30 * time.Second
+	DefaultBackoffMultiplier = 2.0
+)
+
+// ReopenWithBackoff closes the connection and keeps trying to establish a new
+// one until it succeeds or ctx is done. After each failed dial it waits, with
+// the delay starting at BackoffInitial and growing by BackoffMultiplier up to
+// BackoffMax. Each delay is jittered to between half and all of its nominal
+// value, so that many writers reconnecting at once don't dial in lockstep.
+//
+// Writes wait for ReopenWithBackoff to finish. If ctx ends first, the returned
+// error wraps both the context's error and the last dial error.
+func (u *baseWriter) ReopenWithBackoff(ctx context.Context) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.closed = false
+
+	// The connection is being replaced no matter what, so a failure to close
+	// it cleanly shouldn't stop us from dialing
+	u.close()
+
+	delay, max := u.backoffLimits()
+	for {
+		err := u.reconnect(nil)
+		if err == nil {
+			return nil
+		}
+		u.logf("Failed to reopen the connection to %s, retrying in about %s. Underlying error: %s", u.address, delay, err)
+		if sleepErr := u.backoffSleep(ctx, jitter(delay)); sleepErr != nil {
+			return errors.Join(sleepErr, err)
+		}
+		delay = nextBackoff(delay, u.BackoffMultiplier, max)
+	}
+}
+
+// backoffLimits returns the BackoffInitial and BackoffMax to use, with the
+// defaults in place of either if it isn't positive, so that a misconfigured
+// writer can't redial in a tight loop
+func (u *baseWriter) backoffLimits() (initial, max time.Duration) {
+	initial, max = u.BackoffInitial, u.BackoffMax
+	if initial <= 0 {
+		initial = DefaultBackoffInitial
+	}
+	if max <= 0 {
+		max = DefaultBackoffMax
+	}
+	return initial, max
+}
+
+// nextBackoff grows delay by multiplier, capped at max
+func nextBackoff(delay time.Duration, multiplier float64, max time.Duration) time.Duration {
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	// Compare as floats, so a delay too large for a Duration can't overflow
+	next := float64(delay) * multiplier
+	if next >= float64(max) {
+		return max
+	}
+	return time.Duration(next)
+}
+
+// jitter picks a random delay between half and all of d
+func jitter(d time.Duration) time.Duration {
+	if d <= 1 {
+		return d
+	}
+	half := d / 2
+	return half + rand.N(d-half+1)
+}
+
+// sleepContext waits for d, or until ctx is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
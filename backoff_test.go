@@ -0,0 +1,111 @@
+package logopher
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestReopenWithBackoff(t *testing.T) {
+	// One conn to start with, then four failed dials before the fifth succeeds
+	healthy := &fakeConn{}
+	w, _ := newFakeWriter(t, &fakeConn{})
+	failures := 0
+	w.Dialer = func(network, address string) (net.Conn, error) {
+		if failures < 4 {
+			failures++
+			return nil, errors.New("connection refused")
+		}
+		return healthy, nil
+	}
+
+	var delays []time.Duration
+	w.BackoffInitial = 10 * time.Millisecond
+	w.BackoffMax = 50 * time.Millisecond
+	w.BackoffMultiplier = 2
+	w.backoffSleep = func(ctx context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		return nil
+	}
+
+	if err := w.ReopenWithBackoff(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if w.socket != healthy {
+		t.Error("Expected the writer to be using the new connection")
+	}
+	if len(delays) != 4 {
+		t.Fatalf("Expected 4 delays, got %v", delays)
+	}
+	nominal := []time.Duration{10, 20, 40, 50}
+	for i, delay := range delays {
+		max := nominal[i] * time.Millisecond
+		if delay < max/2 || delay > max {
+			t.Errorf("Delay %d was %s, expected between %s and %s", i, delay, max/2, max)
+		}
+	}
+}
+
+func TestReopenWithBackoffCanceled(t *testing.T) {
+	w, _ := newFakeWriter(t, &fakeConn{})
+	dialErr := errors.New("connection refused")
+	w.Dialer = func(network, address string) (net.Conn, error) { return nil, dialErr }
+	w.BackoffInitial = time.Millisecond
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := w.ReopenWithBackoff(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+	if !errors.Is(err, dialErr) {
+		t.Errorf("Expected the last dial error, got %v", err)
+	}
+}
+
+func TestReopenWithBackoffZeroDelays(t *testing.T) {
+	w, _ := newFakeWriter(t, &fakeConn{})
+	healthy := &fakeConn{}
+	failures := 0
+	w.Dialer = func(network, address string) (net.Conn, error) {
+		if failures < 2 {
+			failures++
+			return nil, errors.New("connection refused")
+		}
+		return healthy, nil
+	}
+	w.BackoffInitial = 0
+	w.BackoffMax = -time.Second
+	var delays []time.Duration
+	w.backoffSleep = func(ctx context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		return nil
+	}
+
+	if err := w.ReopenWithBackoff(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(delays) != 2 {
+		t.Fatalf("Expected 2 delays, got %v", delays)
+	}
+	nominal := []time.Duration{DefaultBackoffInitial, 2 * DefaultBackoffInitial}
+	for i, delay := range delays {
+		if delay < nominal[i]/2 || delay > nominal[i] {
+			t.Errorf("Delay %d was %s, expected between %s and %s", i, delay, nominal[i]/2, nominal[i])
+		}
+	}
+}
+
+func TestNextBackoff(t *testing.T) {
+	if next := nextBackoff(time.Second, 2, time.Minute); next != 2*time.Second {
+		t.Errorf("Expected 2s, got %s", next)
+	}
+	if next := nextBackoff(45*time.Second, 2, time.Minute); next != time.Minute {
+		t.Errorf("Expected the delay to be capped at 1m, got %s", next)
+	}
+	if next := nextBackoff(time.Duration(1<<62), 4, time.Duration(1<<63-1)); next != time.Duration(1<<63-1) {
+		t.Errorf("Expected an overflowing delay to be capped, got %s", next)
+	}
+}
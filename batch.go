@@ -0,0 +1,226 @@
+package logopher
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// DefaultMaxBatchBytes is the batch size used when none is given. It keeps a
+// batch inside a single UDP datagram on a typical 1500 byte MTU network.
+const DefaultMaxBatchBytes = 1400
+
+var _ FieldLogger = (*BatchWriter)(nil)
+
+// BatchWriter wraps a Writer and accumulates messages so that many are sent in
+// a single write. Messages are newline delimited, which the LogStash line codec
+// already splits back into events. A batch is flushed when it holds
+// maxMessages messages, when adding another message would take it over
+// maxBytes, or when flushInterval has passed, whichever comes first.
+//
+// A single message larger than maxBytes is sent on its own, rather than being
+// split or dropped.
+type BatchWriter struct {
+	writer      Writer
+	maxMessages int
+	maxBytes    int
+
+	mu    sync.Mutex
+	buf   []byte
+	count int
+	// shut is set, under mu, by the first call to Shutdown
+	shut bool
+
+	stop chan struct{}
+	done chan struct{}
+
+	// DrainTimeout, if positive, bounds each periodic flush, so that a slow
+	// network can't hold up the writer, and every message logged meanwhile,
+	// indefinitely. A flush which runs out of time discards its batch. It only
+	// applies if the wrapped writer supports WriteContext.
+	DrainTimeout time.Duration
+	// OnFlushError, if set, is called from the background goroutine with any
+	// error from a periodic flush, which otherwise has no caller to return it
+	// to
+	OnFlushError func(error)
+}
+
+// NewBatchWriter creates a BatchWriter in front of w. A maxMessages of zero
+// means there is no limit on the number of messages in a batch, a maxBytes of
+// zero means DefaultMaxBatchBytes, and a flushInterval of zero disables the
+// periodic flush. Errors from a periodic flush are discarded, unless
+// OnFlushError is set.
+func NewBatchWriter(w Writer, maxMessages int, maxBytes int, flushInterval time.Duration) *BatchWriter {
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxBatchBytes
+	}
+	b := &BatchWriter{
+		writer:      w,
+		maxMessages: maxMessages,
+		maxBytes:    maxBytes,
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	if flushInterval > 0 {
+		go b.run(flushInterval)
+	} else {
+		close(b.done)
+	}
+	return b
+}
+
+// run flushes the batch every interval until the writer is closed
+func (b *BatchWriter) run(interval time.Duration) {
+	defer close(b.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			b.mu.Lock()
+			err := b.scheduledFlush()
+			b.mu.Unlock()
+			if err != nil && b.OnFlushError != nil {
+				b.OnFlushError(err)
+			}
+		case <-b.stop:
+			return
+		}
+	}
+}
+
+// Log adds msg to the current batch. The byte count returned is the size of the
+// batched payload.
+func (b *BatchWriter) Log(msg string) (int, error) {
+	return b.LogFields(msg, nil)
+}
+
+// LogFields adds msg and fields to the current batch. The byte count returned
+// is the size of the batched payload.
+func (b *BatchWriter) LogFields(msg string, fields map[string]interface{}) (int, error) {
+	data, err := b.encode(msg, fields)
+	if err != nil {
+		return 0, unlessSampledOut(err)
+	}
+	return b.Write(data)
+}
+
+// Write adds rawBytes to the current batch. It should be a complete, newline
+// terminated event. Once the writer has been closed, it fails with ErrClosed.
+func (b *BatchWriter) Write(rawBytes []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.shut {
+		return 0, ErrClosed
+	}
+
+	if len(b.buf)+len(rawBytes) > b.maxBytes {
+		if err := b.flush(); err != nil {
+			return 0, err
+		}
+		if len(rawBytes) > b.maxBytes {
+			// Too big to ever share a batch, so send it alone
+			if _, err := b.writer.Write(rawBytes); err != nil {
+				return 0, err
+			}
+			return len(rawBytes), nil
+		}
+	}
+
+	b.buf = append(b.buf, rawBytes...)
+	b.count++
+	if b.maxMessages > 0 && b.count >= b.maxMessages {
+		if err := b.flush(); err != nil {
+			return 0, err
+		}
+	}
+	return len(rawBytes), nil
+}
+
+// encode builds payloads the same way the wrapped writer would
+func (b *BatchWriter) encode(msg string, fields map[string]interface{}) ([]byte, error) {
+	return encodeFor(b.writer, msg, fields)
+}
+
+// flush writes the current batch, if there is one. The batch is discarded even
+// if the write fails, so one bad batch can't wedge the writer. The caller must
+// hold the mutex.
+func (b *BatchWriter) flush() error {
+	if b.count == 0 {
+		return nil
+	}
+	_, err := b.writer.Write(b.buf)
+	b.buf = b.buf[:0]
+	b.count = 0
+	return err
+}
+
+// Flush writes the current batch without waiting for it to fill up or for the
+// flush interval, then flushes the wrapped writer if it buffers too
+func (b *BatchWriter) Flush() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return errors.Join(b.flush(), flushWriter(b.writer))
+}
+
+// scheduledFlush is flush, bounded by DrainTimeout. The caller must hold the
+// mutex.
+func (b *BatchWriter) scheduledFlush() error {
+	if b.count == 0 {
+		return nil
+	}
+	ctx := context.Background()
+	if b.DrainTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, b.DrainTimeout)
+		defer cancel()
+	}
+	_, err := writeContext(ctx, b.writer, b.buf)
+	b.buf = b.buf[:0]
+	b.count = 0
+	return err
+}
+
+// Reopen re-establishes the wrapped writer's connection. The current batch is
+// kept.
+func (b *BatchWriter) Reopen() error {
+	return b.writer.Reopen()
+}
+
+// Close stops the periodic flush, writes whatever is left in the batch, and
+// then closes the wrapped writer. Calling it again does nothing, and returns
+// nil.
+func (b *BatchWriter) Close() error {
+	return b.Shutdown(context.Background())
+}
+
+// Shutdown stops the periodic flush, writes whatever is left in the batch, and
+// then closes the wrapped writer. If the wrapped writer supports WriteContext,
+// the final flush is bounded by ctx. The wrapped writer is closed even if the
+// final flush fails.
+func (b *BatchWriter) Shutdown(ctx context.Context) error {
+	b.mu.Lock()
+	if b.shut {
+		b.mu.Unlock()
+		return nil
+	}
+	b.shut = true
+	b.mu.Unlock()
+	close(b.stop)
+	select {
+	case <-b.done:
+	case <-ctx.Done():
+		return errors.Join(ctx.Err(), b.writer.Close())
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var flushErr error
+	if b.count > 0 {
+		_, flushErr = writeContext(ctx, b.writer, b.buf)
+		b.buf = b.buf[:0]
+		b.count = 0
+	}
+	return errors.Join(flushErr, b.writer.Close())
+}
@@ -0,0 +1,197 @@
+package logopher
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBatchWriterFlushesOnCount(t *testing.T) {
+	r := &recordingWriter{}
+	b := NewBatchWriter(r, 3, 1<<16, 0)
+	defer b.Close()
+
+	for i := 0; i < 7; i++ {
+		if _, err := b.Write([]byte("event\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	payloads := r.Payloads()
+	if len(payloads) != 2 {
+		t.Fatalf("Expected 2 batches, got %d", len(payloads))
+	}
+	for _, p := range payloads {
+		if string(p) != "event\nevent\nevent\n" {
+			t.Errorf("Unexpected batch %q", p)
+		}
+	}
+}
+
+func TestBatchWriterFlushesOnSize(t *testing.T) {
+	r := &recordingWriter{}
+	b := NewBatchWriter(r, 0, 100, 0)
+	defer b.Close()
+
+	event := []byte(strings.Repeat("x", 39) + "\n")
+	for i := 0; i < 5; i++ {
+		if _, err := b.Write(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	payloads := r.Payloads()
+	if len(payloads) != 2 {
+		t.Fatalf("Expected 2 batches, got %d", len(payloads))
+	}
+	for _, p := range payloads {
+		if len(p) > 100 {
+			t.Errorf("Batch of %d bytes exceeds the limit", len(p))
+		}
+		if !bytes.Equal(p, bytes.Repeat(event, 2)) {
+			t.Errorf("Unexpected batch %q", p)
+		}
+	}
+}
+
+func TestBatchWriterFlushesOnTimer(t *testing.T) {
+	r := &recordingWriter{}
+	b := NewBatchWriter(r, 100, 1<<16, 10*time.Millisecond)
+	defer b.Close()
+
+	if _, err := b.Log("tick"); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for len(r.Payloads()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the timer to flush the batch")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if messages := r.Messages(t); len(messages) != 1 || messages[0] != "tick" {
+		t.Errorf("Unexpected messages %v", messages)
+	}
+}
+
+func TestBatchWriterOversizedMessage(t *testing.T) {
+	r := &recordingWriter{}
+	b := NewBatchWriter(r, 0, 50, 0)
+
+	small := []byte("small\n")
+	huge := []byte(strings.Repeat("h", 200) + "\n")
+	b.Write(small)
+	b.Write(huge)
+	b.Write(small)
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	payloads := r.Payloads()
+	if len(payloads) != 3 {
+		t.Fatalf("Expected 3 writes, got %d", len(payloads))
+	}
+	if !bytes.Equal(payloads[0], small) || !bytes.Equal(payloads[1], huge) || !bytes.Equal(payloads[2], small) {
+		t.Errorf("Expected the oversized message to be sent alone, got %q", payloads)
+	}
+}
+
+func TestBatchWriterShutdown(t *testing.T) {
+	r := &recordingWriter{}
+	b := NewBatchWriter(r, 100, 1<<16, time.Hour)
+	for i := 0; i < 3; i++ {
+		b.Log("pending")
+	}
+	if len(r.Payloads()) != 0 {
+		t.Fatal("Expected the messages to still be batched")
+	}
+
+	if err := b.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if payloads := r.Payloads(); len(payloads) != 1 {
+		t.Fatalf("Expected the batch to be flushed as one payload, got %d", len(payloads))
+	}
+	if events := bytes.Count(r.Payloads()[0], []byte("\n")); events != 3 {
+		t.Errorf("Expected 3 events in the final batch, got %d", events)
+	}
+	if !r.closed {
+		t.Error("Expected the wrapped writer to be closed")
+	}
+}
+
+// stalledWriter is a recordingWriter whose WriteContext never completes until
+// its context is done
+type stalledWriter struct {
+	recordingWriter
+}
+
+func (s *stalledWriter) WriteContext(ctx context.Context, rawBytes []byte) (int, error) {
+	<-ctx.Done()
+	return 0, ctx.Err()
+}
+
+func TestBatchWriterDrainTimeout(t *testing.T) {
+	s := &stalledWriter{}
+	b := NewBatchWriter(s, 0, 1<<16, 10*time.Millisecond)
+	b.DrainTimeout = 50 * time.Millisecond
+	flushErrs := make(chan error, 10)
+	b.OnFlushError = func(err error) { flushErrs <- err }
+
+	if _, err := b.Write([]byte("stuck\n")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-flushErrs:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the stuck flush to time out")
+	}
+
+	start := time.Now()
+	if _, err := b.Write([]byte("still accepted\n")); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected new messages to be accepted promptly, took %s", elapsed)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	b.Shutdown(ctx)
+}
+
+func TestBatchWriterCloseTwice(t *testing.T) {
+	r := &recordingWriter{}
+	b := NewBatchWriter(r, 10, 1<<20, time.Hour)
+	b.Log("pending")
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Close(); err != nil {
+		t.Errorf("Expected a second Close to return nil, got %s", err)
+	}
+	if got := len(r.Payloads()); got != 1 {
+		t.Errorf("Expected the batch to be flushed once, got %d writes", got)
+	}
+}
+
+func TestBatchWriterRefusesMessagesAfterClose(t *testing.T) {
+	r := &recordingWriter{}
+	b := NewBatchWriter(r, 10, 1<<20, time.Hour)
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := b.Log("too late"); err != ErrClosed || n != 0 {
+		t.Errorf("Expected 0 bytes and ErrClosed, got %d and %v", n, err)
+	}
+	if err := b.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(r.Payloads()); got != 0 {
+		t.Errorf("Expected nothing written, got %d writes", got)
+	}
+}
@@ -0,0 +1,239 @@
+package logopher
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+)
+
+var _ FieldLogger = (*BeatsWriter)(nil)
+
+// Lumberjack v2 frame types. Every frame starts with the protocol version,
+// then its type.
+const (
+	lumberjackVersion    = '2'
+	lumberjackWindow     = 'W'
+	lumberjackJSON       = 'J'
+	lumberjackCompressed = 'C'
+	lumberjackAck        = 'A'
+)
+
+// DefaultAckTimeout is how long a BeatsWriter waits for a window to be
+// acknowledged, or a stream writer for its Ack, unless AckTimeout says
+// otherwise
+const DefaultAckTimeout = 30 * time.Second
+
+// BeatsWriter sends messages to LogStash's beats input over tcp, framed with
+// the Lumberjack v2 protocol rather than as lines of JSON. Each Write is sent
+// as one window: every line of rawBytes becomes an event, the events are
+// compressed together, and Write returns once LogStash has acknowledged all of
+// them. Wrapping the writer in a BatchWriter sends a window per batch.
+//
+// A window which isn't acknowledged in time is reported as an error and the
+// connection is reopened, since LogStash may have received some of it. Writes
+// which fail are not replayed, whatever the ReplayBufferSize.
+type BeatsWriter struct {
+	base baseWriter
+	// AckTimeout bounds how long Write waits for LogStash to acknowledge a
+	// window. It defaults to DefaultAckTimeout. A deadline carried by the
+	// context passed to WriteContext is used if it is sooner.
+	AckTimeout time.Duration
+	// CompressionLevel is the zlib level windows are compressed at. It
+	// defaults to zlib.DefaultCompression.
+	CompressionLevel int
+}
+
+// DialBeats creates a BeatsWriter for address, configured by opts the same
+// way New configures a UDPWriter
+func DialBeats(address string, opts ...Option) (*BeatsWriter, error) {
+	address, err := normalizeAddress(address)
+	if err != nil {
+		return nil, err
+	}
+	writer := &BeatsWriter{
+		base:             newBaseWriter("tcp", address, false),
+		AckTimeout:       DefaultAckTimeout,
+		CompressionLevel: zlib.DefaultCompression,
+	}
+	for _, opt := range opts {
+		opt(&writer.base)
+	}
+	// A replayed window would be answered by an ack nobody is waiting for
+	writer.base.ReplayBufferSize = 0
+	// Events are framed, so the newline only serves to split a batch into them
+	writer.base.Terminator = []byte(DefaultTerminator)
+
+	if err := writer.base.open(); err != nil {
+		return nil, err
+	}
+	return writer, nil
+}
+
+// Log crafts a payload body, and sends it to LogStash as a window of one event
+func (b *BeatsWriter) Log(msg string) (int, error) {
+	return b.LogFields(msg, nil)
+}
+
+// LogFields is Log, with fields added to the event
+func (b *BeatsWriter) LogFields(msg string, fields map[string]interface{}) (int, error) {
+	data, err := b.encode(msg, fields)
+	if err != nil {
+		return 0, unlessSampledOut(err)
+	}
+	return b.Write(data)
+}
+
+func (b *BeatsWriter) encode(msg string, fields map[string]interface{}) ([]byte, error) {
+	return b.base.encode(msg, fields)
+}
+
+// Write sends every line of rawBytes as an event, in a single window, and waits
+// for LogStash to acknowledge them. Each line must be a JSON object. Empty
+// lines are skipped.
+func (b *BeatsWriter) Write(rawBytes []byte) (int, error) {
+	return b.WriteContext(context.Background(), rawBytes)
+}
+
+// WriteContext behaves like Write, except that sending the window and waiting
+// for its acknowledgement are bounded by ctx
+func (b *BeatsWriter) WriteContext(ctx context.Context, rawBytes []byte) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	u := &b.base
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	window, count, err := appendWindow(nil, rawBytes, b.CompressionLevel)
+	if err != nil || count == 0 {
+		return 0, err
+	}
+	if _, err := u.write(ctx, window); err != nil {
+		return 0, err
+	}
+	if err := b.awaitAck(ctx, count); err != nil {
+		// The window was written, but can't be counted as delivered
+		u.stats.messagesWritten.Add(^uint64(0))
+		u.stats.writeErrors.Add(1)
+		u.logf("No acknowledgement from %s for a window of %d events. Underlying error: %s", u.address, count, err)
+		ackErr := fmt.Errorf("logopher: window of %d events was not acknowledged: %w", count, err)
+		u.lastErr = ackErr
+		// Whatever LogStash sends next can't be trusted to belong to the next
+		// window, so start again on a fresh connection
+		u.close()
+		if reconnectErr := u.reconnect(err); reconnectErr != nil {
+			u.logf("Failed to reconnect to %s after a window went unacknowledged. Underlying error: %s", u.address, reconnectErr)
+		}
+		if ctxErr := contextError(ctx); ctxErr != nil {
+			return 0, ctxErr
+		}
+		return 0, ackErr
+	}
+	return len(rawBytes), nil
+}
+
+// awaitAck reads acks until one covers all count events of the window just
+// sent. LogStash may acknowledge part of a window first, to show it is still
+// working on a large one. The caller must hold the mutex.
+func (b *BeatsWriter) awaitAck(ctx context.Context, count int) error {
+	timeout := b.AckTimeout
+	if timeout <= 0 {
+		timeout = DefaultAckTimeout
+	}
+	deadline := time.Now().Add(timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	socket := b.base.socket
+	socket.SetReadDeadline(deadline)
+	defer socket.SetReadDeadline(time.Time{})
+
+	var ack [6]byte
+	for {
+		if _, err := io.ReadFull(socket, ack[:]); err != nil {
+			return err
+		}
+		if ack[0] != lumberjackVersion || ack[1] != lumberjackAck {
+			return fmt.Errorf("unexpected frame %q", ack[:2])
+		}
+		if seq := binary.BigEndian.Uint32(ack[2:]); seq >= uint32(count) {
+			return nil
+		}
+	}
+}
+
+// appendWindow frames every line of events as a Lumberjack window, appending it
+// to dst. The window frame announcing how many events follow is sent as is,
+// and the events' json frames, numbered from 1, are sent inside a compressed
+// frame. It returns how many events were framed.
+func appendWindow(dst, events []byte, level int) ([]byte, int, error) {
+	var frames bytes.Buffer
+	count := 0
+	for len(events) > 0 {
+		var event []byte
+		event, events, _ = bytes.Cut(events, []byte{'\n'})
+		if len(event) == 0 {
+			continue
+		}
+		count++
+		frames.Write([]byte{lumberjackVersion, lumberjackJSON})
+		frames.Write(binary.BigEndian.AppendUint32(nil, uint32(count)))
+		frames.Write(binary.BigEndian.AppendUint32(nil, uint32(len(event))))
+		frames.Write(event)
+	}
+	if count == 0 {
+		return dst, 0, nil
+	}
+
+	var compressed bytes.Buffer
+	zw, err := zlib.NewWriterLevel(&compressed, level)
+	if err != nil {
+		return dst, 0, err
+	}
+	zw.Write(frames.Bytes())
+	if err := zw.Close(); err != nil {
+		return dst, 0, err
+	}
+
+	dst = append(dst, lumberjackVersion, lumberjackWindow)
+	dst = binary.BigEndian.AppendUint32(dst, uint32(count))
+	dst = append(dst, lumberjackVersion, lumberjackCompressed)
+	dst = binary.BigEndian.AppendUint32(dst, uint32(compressed.Len()))
+	return append(dst, compressed.Bytes()...), count, nil
+}
+
+// IsOpen reports whether the writer has a connection
+func (b *BeatsWriter) IsOpen() bool {
+	return b.base.IsOpen()
+}
+
+// LastError returns the most recent error from dialing, writing or waiting for
+// an acknowledgement, or nil if there has never been one
+func (b *BeatsWriter) LastError() error {
+	return b.base.LastError()
+}
+
+// Stats returns a snapshot of the writer's delivery counters, where each window
+// counts as one message
+func (b *BeatsWriter) Stats() Stats {
+	return b.base.Stats()
+}
+
+// Flush does nothing, since every Write is sent, and acknowledged, immediately
+func (b *BeatsWriter) Flush() error {
+	return nil
+}
+
+// Reopen closes and re-establishes the connection to LogStash
+func (b *BeatsWriter) Reopen() error {
+	return b.base.Reopen()
+}
+
+// Close closes the connection to LogStash
+func (b *BeatsWriter) Close() error {
+	return b.base.Close()
+}
@@ -0,0 +1,221 @@
+package logopher
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readWindow decodes a Lumberjack v2 window from r, returning its events in
+// the order of their sequence numbers
+func readWindow(r io.Reader) ([][]byte, error) {
+	var header [6]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	if string(header[:2]) != "2W" {
+		return nil, fmt.Errorf("expected a window frame, got %q", header[:2])
+	}
+	count := int(binary.BigEndian.Uint32(header[2:]))
+
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	if string(header[:2]) != "2C" {
+		return nil, fmt.Errorf("expected a compressed frame, got %q", header[:2])
+	}
+	compressed := make([]byte, binary.BigEndian.Uint32(header[2:]))
+	if _, err := io.ReadFull(r, compressed); err != nil {
+		return nil, err
+	}
+	zr, err := zlib.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, err
+	}
+	frames := bufio.NewReader(zr)
+
+	events := make([][]byte, count)
+	for i := range events {
+		var frame [10]byte
+		if _, err := io.ReadFull(frames, frame[:]); err != nil {
+			return nil, err
+		}
+		if string(frame[:2]) != "2J" {
+			return nil, fmt.Errorf("expected a json frame, got %q", frame[:2])
+		}
+		if seq := binary.BigEndian.Uint32(frame[2:6]); seq != uint32(i+1) {
+			return nil, fmt.Errorf("expected sequence number %d, got %d", i+1, seq)
+		}
+		events[i] = make([]byte, binary.BigEndian.Uint32(frame[6:]))
+		if _, err := io.ReadFull(frames, events[i]); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := frames.ReadByte(); err != io.EOF {
+		return nil, fmt.Errorf("expected the compressed frame to end after %d events", count)
+	}
+	return events, nil
+}
+
+// ack builds an ack frame for seq
+func ack(seq uint32) []byte {
+	return binary.BigEndian.AppendUint32([]byte("2A"), seq)
+}
+
+// listenBeats runs a beats input which decodes every window it's sent and
+// hands the events to respond, which returns what to send back
+func listenBeats(t *testing.T, respond func(events [][]byte) []byte) (net.Listener, <-chan [][]byte) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	windows := make(chan [][]byte, 100)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				for {
+					events, err := readWindow(conn)
+					if err != nil {
+						return
+					}
+					windows <- events
+					conn.Write(respond(events))
+				}
+			}()
+		}
+	}()
+	return l, windows
+}
+
+func ackAll(events [][]byte) []byte {
+	return ack(uint32(len(events)))
+}
+
+func TestAppendWindow(t *testing.T) {
+	window, count, err := appendWindow(nil, []byte("{\"a\":1}\n\n{\"b\":2}\n"), zlib.DefaultCompression)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("Expected 2 events, got %d", count)
+	}
+	events, err := readWindow(bytes.NewReader(window))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || string(events[0]) != `{"a":1}` || string(events[1]) != `{"b":2}` {
+		t.Errorf("Expected both events without their newlines, got %q", events)
+	}
+
+	if _, count, _ := appendWindow(nil, []byte("\n"), zlib.DefaultCompression); count != 0 {
+		t.Errorf("Expected no events from an empty line, got %d", count)
+	}
+}
+
+func TestBeatsWriterLog(t *testing.T) {
+	l, windows := listenBeats(t, ackAll)
+	w, err := DialBeats(l.Addr().String(), WithHost("beats-host"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	if _, err := w.LogFields("hello", map[string]interface{}{"k": "v"}); err != nil {
+		t.Fatal(err)
+	}
+	events := <-windows
+	if len(events) != 1 {
+		t.Fatalf("Expected a window of 1 event, got %d", len(events))
+	}
+	var event map[string]interface{}
+	if err := json.Unmarshal(events[0], &event); err != nil {
+		t.Fatal(err)
+	}
+	if event["message"] != "hello" || event["k"] != "v" || event["host"] != "beats-host" {
+		t.Errorf("Expected the encoded event, got %v", event)
+	}
+}
+
+func TestBeatsWriterBatch(t *testing.T) {
+	l, windows := listenBeats(t, ackAll)
+	w, err := DialBeats(l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := NewBatchWriter(w, 3, 0, 0)
+	defer b.Close()
+
+	for i := 0; i < 3; i++ {
+		b.Log(fmt.Sprintf("event %d", i))
+	}
+	events := <-windows
+	if len(events) != 3 {
+		t.Fatalf("Expected the batch to be sent as a window of 3, got %d", len(events))
+	}
+	for i, e := range events {
+		if !strings.Contains(string(e), fmt.Sprintf(`"event %d"`, i)) {
+			t.Errorf("Expected event %d in order, got %s", i, e)
+		}
+	}
+}
+
+func TestBeatsWriterPartialAck(t *testing.T) {
+	l, _ := listenBeats(t, func(events [][]byte) []byte {
+		return append(ack(1), ack(uint32(len(events)))...)
+	})
+	w, err := DialBeats(l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	payload := []byte("{\"a\":1}\n{\"b\":2}\n")
+	n, err := w.Write(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(payload) {
+		t.Errorf("Expected %d bytes written, got %d", len(payload), n)
+	}
+	// The partial ack must not be taken for the next window's
+	if _, err := w.Write(payload); err != nil {
+		t.Errorf("Expected the second window to be acknowledged, got %v", err)
+	}
+}
+
+func TestBeatsWriterAckTimeout(t *testing.T) {
+	l, windows := listenBeats(t, func([][]byte) []byte { return nil })
+	w, err := DialBeats(l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	w.AckTimeout = 50 * time.Millisecond
+
+	if _, err := w.Log("unacknowledged"); err == nil || !strings.Contains(err.Error(), "not acknowledged") {
+		t.Errorf("Expected the window to go unacknowledged, got %v", err)
+	}
+	<-windows
+	stats := w.Stats()
+	if stats.Reconnects < 1 {
+		t.Error("Expected the connection to be reopened")
+	}
+	if stats.MessagesWritten != 0 || stats.WriteErrors != 1 {
+		t.Errorf("Expected the window to count as a write error, got %+v", stats)
+	}
+}
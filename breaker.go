@@ -0,0 +1,182 @@
+package logopher
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var _ FieldLogger = (*BreakerWriter)(nil)
+
+// ErrCircuitOpen is returned by a BreakerWriter which is refusing writes
+var ErrCircuitOpen = errors.New("logopher: circuit breaker is open")
+
+// BreakerState is the state of a BreakerWriter's circuit
+type BreakerState int
+
+// The states a circuit moves through. It starts closed, opens after too many
+// failures in a row, half-opens once the cooldown has passed, and closes again
+// as soon as a write succeeds.
+const (
+	BreakerClosed BreakerState = iota
+	BreakerOpen
+	BreakerHalfOpen
+)
+
+// String returns the name of the state
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("BreakerState(%d)", int(s))
+}
+
+// BreakerWriter wraps a Writer with a circuit breaker, so that while LogStash
+// is down, writes fail immediately instead of each waiting on the wrapped
+// writer's retries and reconnects. After a number of consecutive failures the
+// circuit opens, and every write fails with ErrCircuitOpen without touching the
+// network. Once the cooldown has passed the circuit is half-open: one write is
+// let through as a probe, while the others keep failing. If the probe succeeds
+// the circuit closes, and if it fails the circuit opens for another cooldown.
+type BreakerWriter struct {
+	writer    Writer
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+	now      func() time.Time
+
+	// Drop, when true, discards messages while the circuit is open, reporting
+	// 0 bytes written and no error, rather than returning ErrCircuitOpen
+	Drop bool
+}
+
+// NewBreakerWriter creates a BreakerWriter in front of w, which opens after
+// failures consecutive failed writes and stays open for cooldown. A failures of
+// less than one is treated as one.
+func NewBreakerWriter(w Writer, failures int, cooldown time.Duration) *BreakerWriter {
+	if failures < 1 {
+		failures = 1
+	}
+	return &BreakerWriter{
+		writer:    w,
+		threshold: failures,
+		cooldown:  cooldown,
+		now:       time.Now,
+	}
+}
+
+// Log writes msg, unless the circuit is open
+func (b *BreakerWriter) Log(msg string) (int, error) {
+	return b.LogFields(msg, nil)
+}
+
+// LogFields writes msg and fields, unless the circuit is open
+func (b *BreakerWriter) LogFields(msg string, fields map[string]interface{}) (int, error) {
+	data, err := b.encode(msg, fields)
+	if err != nil {
+		return 0, unlessSampledOut(err)
+	}
+	return b.Write(data)
+}
+
+// Write writes rawBytes, unless the circuit is open
+func (b *BreakerWriter) Write(rawBytes []byte) (int, error) {
+	if !b.allow() {
+		if b.Drop {
+			return 0, nil
+		}
+		return 0, ErrCircuitOpen
+	}
+	n, err := b.writer.Write(rawBytes)
+	b.record(err)
+	return n, err
+}
+
+// encode builds payloads the same way the wrapped writer would
+func (b *BreakerWriter) encode(msg string, fields map[string]interface{}) ([]byte, error) {
+	return encodeFor(b.writer, msg, fields)
+}
+
+// State returns the state of the circuit
+func (b *BreakerWriter) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.cool()
+	return b.state
+}
+
+// cool half-opens the circuit if it has been open for the cooldown. The caller
+// must hold the mutex.
+func (b *BreakerWriter) cool() {
+	if b.state == BreakerOpen && b.now().Sub(b.openedAt) >= b.cooldown {
+		b.state = BreakerHalfOpen
+		b.probing = false
+	}
+}
+
+// allow reports whether a write may go through, claiming the probe if the
+// circuit is half-open
+func (b *BreakerWriter) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.cool()
+	switch b.state {
+	case BreakerOpen:
+		return false
+	case BreakerHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+	}
+	return true
+}
+
+// record moves the circuit on according to the outcome of a write
+func (b *BreakerWriter) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err == nil {
+		b.state = BreakerClosed
+		b.failures = 0
+		b.probing = false
+		return
+	}
+	b.failures++
+	if b.state == BreakerHalfOpen || b.failures >= b.threshold {
+		b.state = BreakerOpen
+		b.openedAt = b.now()
+		b.probing = false
+	}
+}
+
+// Flush flushes the wrapped writer, if it buffers messages
+func (b *BreakerWriter) Flush() error {
+	return flushWriter(b.writer)
+}
+
+// Reopen re-establishes the wrapped writer's connection. If it succeeds the
+// circuit is closed, since the connection is known to be good again.
+func (b *BreakerWriter) Reopen() error {
+	err := b.writer.Reopen()
+	if err == nil {
+		b.record(nil)
+	}
+	return err
+}
+
+// Close closes the wrapped writer
+func (b *BreakerWriter) Close() error {
+	return b.writer.Close()
+}
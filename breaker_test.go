@@ -0,0 +1,132 @@
+package logopher
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// newTestBreaker returns a BreakerWriter in front of r whose clock only moves
+// when the returned function is called
+func newTestBreaker(r *recordingWriter, failures int, cooldown time.Duration) (*BreakerWriter, func(time.Duration)) {
+	b := NewBreakerWriter(r, failures, cooldown)
+	now := time.Unix(1000, 0)
+	b.now = func() time.Time { return now }
+	return b, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestBreakerWriterTransitions(t *testing.T) {
+	r := &recordingWriter{}
+	b, advance := newTestBreaker(r, 3, time.Minute)
+	down := errors.New("connection refused")
+
+	if s := b.State(); s != BreakerClosed {
+		t.Fatalf("Expected the circuit to start closed, got %s", s)
+	}
+
+	r.writeErr = down
+	for i := 0; i < 3; i++ {
+		if _, err := b.Log("failing"); !errors.Is(err, down) {
+			t.Fatalf("Expected failure %d to come from the writer, got %v", i+1, err)
+		}
+	}
+	if s := b.State(); s != BreakerOpen {
+		t.Fatalf("Expected the circuit to open after 3 failures, got %s", s)
+	}
+
+	// While open, nothing reaches the writer, even once it has recovered
+	r.writeErr = nil
+	if _, err := b.Log("rejected"); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected ErrCircuitOpen, got %v", err)
+	}
+	if len(r.Payloads()) != 0 {
+		t.Error("Expected nothing written while the circuit is open")
+	}
+
+	advance(time.Minute)
+	if s := b.State(); s != BreakerHalfOpen {
+		t.Fatalf("Expected the circuit to half-open after the cooldown, got %s", s)
+	}
+
+	if _, err := b.Log("probe"); err != nil {
+		t.Fatalf("Expected the probe to be let through, got %v", err)
+	}
+	if s := b.State(); s != BreakerClosed {
+		t.Errorf("Expected a successful probe to close the circuit, got %s", s)
+	}
+	if written := len(r.Payloads()); written != 1 {
+		t.Errorf("Expected only the probe to be written, got %d", written)
+	}
+}
+
+func TestBreakerWriterFailedProbe(t *testing.T) {
+	r := &recordingWriter{writeErr: errors.New("still down")}
+	b, advance := newTestBreaker(r, 1, time.Minute)
+
+	b.Log("opens")
+	advance(time.Minute)
+	b.Log("probe")
+	if s := b.State(); s != BreakerOpen {
+		t.Fatalf("Expected a failed probe to reopen the circuit, got %s", s)
+	}
+
+	advance(time.Minute - time.Second)
+	if s := b.State(); s != BreakerOpen {
+		t.Errorf("Expected a full cooldown after the failed probe, got %s", s)
+	}
+}
+
+func TestBreakerWriterOneProbe(t *testing.T) {
+	r := &recordingWriter{writeErr: errors.New("down")}
+	b, advance := newTestBreaker(r, 1, time.Minute)
+	b.Log("opens")
+	advance(time.Minute)
+
+	// Claim the probe without finishing it, as a write still in flight would
+	if !b.allow() {
+		t.Fatal("Expected the first write after the cooldown to probe")
+	}
+	if _, err := b.Log("while probing"); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected other writes to fail while the probe is out, got %v", err)
+	}
+}
+
+func TestBreakerWriterConsecutiveFailures(t *testing.T) {
+	r := &recordingWriter{}
+	b, _ := newTestBreaker(r, 2, time.Minute)
+	down := errors.New("down")
+
+	r.writeErr = down
+	b.Log("fails")
+	r.writeErr = nil
+	b.Log("succeeds")
+	r.writeErr = down
+	b.Log("fails again")
+	if s := b.State(); s != BreakerClosed {
+		t.Errorf("Expected a success to reset the failure count, got %s", s)
+	}
+}
+
+func TestBreakerWriterDrop(t *testing.T) {
+	r := &recordingWriter{writeErr: errors.New("down")}
+	b, _ := newTestBreaker(r, 1, time.Minute)
+	b.Drop = true
+
+	b.Log("opens")
+	if n, err := b.Log("dropped"); n != 0 || err != nil {
+		t.Errorf("Expected (0, nil) for a dropped message, got (%d, %v)", n, err)
+	}
+}
+
+func TestBreakerWriterReopenCloses(t *testing.T) {
+	r := &recordingWriter{writeErr: errors.New("down")}
+	b, _ := newTestBreaker(r, 1, time.Minute)
+
+	b.Log("opens")
+	if err := b.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	if s := b.State(); s != BreakerClosed {
+		t.Errorf("Expected a successful Reopen to close the circuit, got %s", s)
+	}
+}
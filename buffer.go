@@ -0,0 +1,46 @@
+package logopher
+
+import (
+	"errors"
+	"net"
+)
+
+// errNoWriteBuffer is returned when the connection has no send buffer to size
+var errNoWriteBuffer = errors.New("logopher: connection does not support setting the send buffer size")
+
+// SetSendBufferSize asks the operating system to use a send buffer of the given
+// size for the connection, which can prevent the kernel silently dropping bursts
+// of udp traffic. The size is kept, and applied again whenever the connection
+// is reopened. This is best-effort: the operating system may adjust or cap the
+// size, and the limits vary between platforms. While the writer isn't
+// connected, the size is only kept.
+func (u *baseWriter) SetSendBufferSize(bytes int) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.sendBufferSize = bytes
+	if u.socket == nil {
+		return nil
+	}
+	return setWriteBuffer(u.socket, bytes)
+}
+
+// WithSendBufferSize asks for a send buffer of the given size on every
+// connection, including the first, as SetSendBufferSize does.
+func WithSendBufferSize(bytes int) Option {
+	return func(u *baseWriter) {
+		u.sendBufferSize = bytes
+	}
+}
+
+// setWriteBuffer sets the send buffer size of conn, or of the connection
+// beneath it for tls
+func setWriteBuffer(conn net.Conn, bytes int) error {
+	if nc, ok := conn.(interface{ NetConn() net.Conn }); ok {
+		conn = nc.NetConn()
+	}
+	wb, ok := conn.(interface{ SetWriteBuffer(int) error })
+	if !ok {
+		return errNoWriteBuffer
+	}
+	return wb.SetWriteBuffer(bytes)
+}
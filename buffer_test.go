@@ -0,0 +1,88 @@
+package logopher
+
+import (
+	"crypto/tls"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestSetSendBufferSize(t *testing.T) {
+	l := listenUDP(t)
+	w, err := DialUDP(l.LocalAddr().String(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	if err := w.SetSendBufferSize(1 << 20); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	if w.sendBufferSize != 1<<20 {
+		t.Error("Expected the size to be kept for the next connection")
+	}
+}
+
+func TestSetSendBufferSizeTCPAndTLS(t *testing.T) {
+	l, _ := listenTCP(t)
+	tcp, err := DialTCP(l.Addr().String(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tcp.Close()
+	if err := tcp.SetSendBufferSize(1 << 16); err != nil {
+		t.Errorf("tcp: %v", err)
+	}
+
+	cert, pool := selfSignedCert(t)
+	tl, _ := listenTLS(t, cert)
+	secure, err := DialTLS(tl.Addr().String(), &tls.Config{RootCAs: pool}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer secure.Close()
+	if err := secure.SetSendBufferSize(1 << 16); err != nil {
+		t.Errorf("tls: %v", err)
+	}
+}
+
+func TestSetSendBufferSizeUnsupported(t *testing.T) {
+	w, _ := newFakeWriter(t, &fakeConn{})
+	if err := w.SetSendBufferSize(1 << 16); err != errNoWriteBuffer {
+		t.Errorf("Expected errNoWriteBuffer, got %v", err)
+	}
+}
+
+func TestWithSendBufferSize(t *testing.T) {
+	l := listenUDP(t)
+	captured := &captureLogger{}
+	w, err := New(l.LocalAddr().String(), WithSendBufferSize(1<<20), WithLogging(true), WithLogger(captured))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if w.sendBufferSize != 1<<20 {
+		t.Error("Expected the size to be kept")
+	}
+	if len(captured.lines) != 0 {
+		t.Errorf("Expected the send buffer to be sized cleanly, got %q", captured.lines)
+	}
+}
+
+func TestWithSendBufferSizeAppliesToFirstConnection(t *testing.T) {
+	captured := &captureLogger{}
+	fake := func(u *baseWriter) {
+		u.Dialer = func(network, address string) (net.Conn, error) { return &fakeConn{}, nil }
+	}
+	w, err := NewTCP("fake:5000", fake, WithSendBufferSize(1<<16), WithLogging(true), WithLogger(captured))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if len(captured.lines) != 1 || !strings.Contains(captured.lines[0], "send buffer") {
+		t.Errorf("Expected the send buffer to be sized on the first connection, got %q", captured.lines)
+	}
+}
@@ -0,0 +1,70 @@
+package logopher
+
+import (
+	"bytes"
+	"compress/gzip"
+	"sync"
+)
+
+// gzipPool recycles the compressors used for large datagrams
+var gzipPool = sync.Pool{
+	New: func() interface{} {
+		return gzip.NewWriter(nil)
+	},
+}
+
+// WithCompressAbove gzips every udp payload larger than n bytes. See
+// CompressAbove.
+//
+// LogStash's udp input can't tell compressed datagrams from plain ones by
+// itself. Read them as binary, and gunzip those starting with the magic number
+// before parsing the JSON:
+//
+//	input {
+//	  udp {
+//	    port  => 5000
+//	    codec => plain { charset => "BINARY" }
+//	  }
+//	}
+//	filter {
+//	  ruby {
+//	    init => "require 'zlib'; require 'stringio'"
+//	    code => "m = event.get('message'); event.set('message', Zlib::GzipReader.new(StringIO.new(m)).read) if m.getbyte(0) == 0x1f && m.getbyte(1) == 0x8b"
+//	  }
+//	  json { source => "message" }
+//	}
+//
+// If every datagram is compressed, as with an n of 1, the udp input's
+// gzip_lines codec can decompress them instead.
+func WithCompressAbove(n int) Option {
+	return func(u *baseWriter) {
+		u.CompressAbove = n
+	}
+}
+
+// compresses reports whether a payload of size bytes is to be gzipped before
+// it is sent
+func (u *baseWriter) compresses(size int) bool {
+	return u.datagram && u.CompressAbove > 0 && size > u.CompressAbove
+}
+
+// gzipPayload compresses payload into a single, complete gzip member, which
+// begins with the gzip magic number so a receiver can tell it from JSON
+func gzipPayload(payload []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Grow(len(payload) / 2)
+	gz := gzipPool.Get().(*gzip.Writer)
+	defer func() {
+		// Let go of buf, which belongs to the caller from here on
+		gz.Reset(nil)
+		gzipPool.Put(gz)
+	}()
+	gz.Reset(&buf)
+	if _, err := gz.Write(payload); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
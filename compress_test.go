@@ -0,0 +1,82 @@
+package logopher
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestCompressAbove(t *testing.T) {
+	l := listenUDP(t)
+	w, err := New(l.LocalAddr().String(), WithCompressAbove(256))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	if _, err := w.Log("small"); err != nil {
+		t.Fatal(err)
+	}
+	if small := readDatagram(t, l); small[0] != '{' {
+		t.Errorf("Expected a small message to be sent as it is, got %q", small)
+	}
+
+	large, err := w.encode(strings.Repeat("a large and repetitive message ", 100), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := w.Write(large)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(large) {
+		t.Errorf("Expected %d bytes reported written, got %d", len(large), n)
+	}
+	datagram := readDatagram(t, l)
+	if len(datagram) < 2 || datagram[0] != 0x1f || datagram[1] != 0x8b {
+		t.Fatalf("Expected a large message to start with the gzip magic number, got %q", datagram[:2])
+	}
+	if len(datagram) >= len(large) {
+		t.Errorf("Expected the datagram to be smaller than the %d byte message, got %d bytes", len(large), len(datagram))
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(datagram))
+	if err != nil {
+		t.Fatal(err)
+	}
+	decompressed, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decompressed, large) {
+		t.Errorf("Expected the datagram to decompress to\n%s\ngot\n%s", large, decompressed)
+	}
+}
+
+func TestCompressAboveFitsDatagram(t *testing.T) {
+	l := listenUDP(t)
+	w, err := New(l.LocalAddr().String(), WithCompressAbove(1024))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	w.MaxDatagramSize = 2048
+
+	// Too big to send as it is, but not once compressed
+	if _, err := w.Write([]byte(strings.Repeat("x", 4096))); err != nil {
+		t.Fatalf("Expected the compressed message to fit, got %s", err)
+	}
+	readDatagram(t, l)
+}
+
+func TestCompressAboveIgnoresStreams(t *testing.T) {
+	conn := &fakeConn{}
+	w, _ := newFakeWriter(t, conn)
+	w.CompressAbove = 1
+
+	w.Log("over tcp")
+	if written := conn.Writes()[0]; written[0] != '{' {
+		t.Errorf("Expected a stream writer not to compress, got %q", written)
+	}
+}
@@ -0,0 +1,116 @@
+package logopher
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// aLongTimeAgo is a deadline in the past, used to interrupt a blocked write
+var aLongTimeAgo = time.Unix(1, 0)
+
+// LogContext crafts a payload body, and writes it to logstash, giving up when
+// ctx is done. See WriteContext. If a ContextExtractor is set, the fields it
+// finds in ctx are added to the message.
+func (u *baseWriter) LogContext(ctx context.Context, msg string) (int, error) {
+	return u.LogFieldsContext(ctx, msg, nil)
+}
+
+// LogFieldsContext is LogContext, with fields added to the message. They take
+// precedence over any of the same name found by the ContextExtractor.
+func (u *baseWriter) LogFieldsContext(ctx context.Context, msg string, fields map[string]interface{}) (int, error) {
+	if u.Sampler != nil && !u.Sampler.Sample() {
+		return 0, nil
+	}
+	data, err := u.payload(msg, u.withContextFields(ctx, fields))
+	if err != nil {
+		return 0, err
+	}
+	return u.WriteContext(ctx, data)
+}
+
+// withContextFields merges fields over those the ContextExtractor finds in ctx,
+// without modifying either
+func (u *baseWriter) withContextFields(ctx context.Context, fields map[string]interface{}) map[string]interface{} {
+	if u.ContextExtractor == nil {
+		return fields
+	}
+	extracted := u.ContextExtractor(ctx)
+	if len(extracted) == 0 {
+		return fields
+	}
+	merged := make(map[string]interface{}, len(extracted)+len(fields))
+	for k, v := range extracted {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return merged
+}
+
+// WriteContext behaves like Write, except that the write is bounded by ctx. The
+// context's deadline is applied to the socket, and canceling the context
+// interrupts a write which is blocked, which is mostly a concern for TCP and
+// TLS. When the write is cut short, the connection is closed like any other
+// failed write, and the context's error is returned.
+func (u *baseWriter) WriteContext(ctx context.Context, rawBytes []byte) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.write(ctx, rawBytes)
+}
+
+// contextWriter is implemented by writers whose writes can be bounded by a
+// context
+type contextWriter interface {
+	WriteContext(ctx context.Context, rawBytes []byte) (int, error)
+}
+
+// writeContext writes rawBytes to w, bounded by ctx if w supports it
+func writeContext(ctx context.Context, w Writer, rawBytes []byte) (int, error) {
+	if cw, ok := w.(contextWriter); ok {
+		return cw.WriteContext(ctx, rawBytes)
+	}
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return w.Write(rawBytes)
+}
+
+// contextError reports whether ctx is done. Unlike ctx.Err, it treats a passed
+// deadline as exceeded straight away, since the socket deadline can fire a
+// moment before the context's own timer does.
+func contextError(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok && !time.Now().Before(deadline) {
+		return context.DeadlineExceeded
+	}
+	return nil
+}
+
+// applyContext sets the socket's write deadline from ctx, and arranges for the
+// write to be interrupted if ctx is canceled. The returned function undoes both,
+// and must be called once the write is finished.
+func applyContext(ctx context.Context, socket net.Conn) func() {
+	if deadline, ok := ctx.Deadline(); ok {
+		socket.SetWriteDeadline(deadline)
+	}
+	interrupted := make(chan struct{})
+	stop := context.AfterFunc(ctx, func() {
+		socket.SetWriteDeadline(aLongTimeAgo)
+		close(interrupted)
+	})
+	return func() {
+		if !stop() {
+			// The interrupt already fired, or is firing. Wait for it, so it
+			// can't land after the deadline has been cleared.
+			<-interrupted
+		}
+		socket.SetWriteDeadline(time.Time{})
+	}
+}
@@ -0,0 +1,246 @@
+package logopher
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+// listenStalled opens a local TCP listener which accepts connections but never
+// reads from them, so writes eventually block once the socket buffers fill
+func listenStalled(t *testing.T) net.Listener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { conn.Close() })
+		}
+	}()
+	return l
+}
+
+// stalledPayload is large enough to overwhelm the socket buffers
+var stalledPayload = bytes.Repeat([]byte("x"), 64<<20)
+
+func TestWriteContextDeadline(t *testing.T) {
+	l := listenStalled(t)
+	w, err := DialTCP(l.Addr().String(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = w.WriteContext(ctx, stalledPayload)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected the write to give up promptly, took %s", elapsed)
+	}
+}
+
+func TestWriteContextCancel(t *testing.T) {
+	l := listenStalled(t)
+	w, err := DialTCP(l.Addr().String(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	if _, err := w.WriteContext(ctx, stalledPayload); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestWriteContextCancelDoesNotRedial(t *testing.T) {
+	l := listenStalled(t)
+	w, err := DialTCP(l.Addr().String(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	dials := 0
+	w.Dialer = func(network, address string) (net.Conn, error) {
+		dials++
+		return net.Dial(network, address)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	if _, err := w.WriteContext(ctx, stalledPayload); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if dials != 0 {
+		t.Errorf("Expected no redial once the context was canceled, got %d dials", dials)
+	}
+}
+
+func TestLogContextAlreadyCanceled(t *testing.T) {
+	conn := &fakeConn{}
+	w, _ := newFakeWriter(t, conn)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := w.LogContext(ctx, "never sent"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if len(conn.Writes()) != 0 {
+		t.Error("Expected nothing to be written")
+	}
+}
+
+func TestLogContextSucceeds(t *testing.T) {
+	l, lines := listenTCP(t)
+	w, err := DialTCP(l.Addr().String(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := w.LogContext(ctx, "in time"); err != nil {
+		t.Fatal(err)
+	}
+	readLine(t, lines)
+
+	// Neither the deadline nor the cancellation may linger on the socket
+	cancel()
+	if _, err := w.Log("after the context"); err != nil {
+		t.Errorf("Expected a plain write to succeed, got %v", err)
+	}
+}
+
+func TestWriteTimeout(t *testing.T) {
+	l := listenStalled(t)
+	w, err := DialTCP(l.Addr().String(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	w.WriteTimeout = 100 * time.Millisecond
+
+	before := w.socket
+	start := time.Now()
+	_, err = w.Write(stalledPayload)
+	if !isTimeout(err) {
+		t.Errorf("Expected a timeout error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected the write to give up promptly, took %s", elapsed)
+	}
+	if w.socket == before {
+		t.Error("Expected the timed out connection to be replaced")
+	}
+}
+
+func TestWriteContextDeadlineOverridesWriteTimeout(t *testing.T) {
+	conn := &fakeConn{}
+	w, _ := newFakeWriter(t, conn)
+	w.WriteTimeout = 10 * time.Millisecond
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	want, _ := ctx.Deadline()
+	if _, err := w.WriteContext(ctx, []byte("large\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	deadlines := conn.Deadlines()
+	if len(deadlines) == 0 {
+		t.Fatal("Expected a write deadline to be set")
+	}
+	for _, d := range deadlines[:len(deadlines)-1] {
+		if !d.Equal(want) {
+			t.Errorf("Expected the context's deadline %s to be used, got %s", want, d)
+		}
+	}
+	if last := deadlines[len(deadlines)-1]; !last.IsZero() {
+		t.Errorf("Expected the deadline to be cleared after the write, got %s", last)
+	}
+}
+
+func TestWriteTimeoutClearedAfterWrite(t *testing.T) {
+	conn := &fakeConn{}
+	w, _ := newFakeWriter(t, conn)
+	w.WriteTimeout = 10 * time.Millisecond
+
+	start := time.Now()
+	if _, err := w.Write([]byte("small\n")); err != nil {
+		t.Fatal(err)
+	}
+	deadlines := conn.Deadlines()
+	if len(deadlines) != 2 {
+		t.Fatalf("Expected the deadline to be set and then cleared, got %v", deadlines)
+	}
+	if d := deadlines[0].Sub(start); d < 10*time.Millisecond || d > time.Second {
+		t.Errorf("Expected a deadline WriteTimeout from now, got %s from now", d)
+	}
+	if !deadlines[1].IsZero() {
+		t.Errorf("Expected the deadline to be cleared after the write, got %s", deadlines[1])
+	}
+
+	// Without a timeout of its own, a later per-call write is left unbounded
+	// rather than inheriting the earlier deadline
+	w.WriteTimeout = 0
+	if _, err := w.WriteContext(context.Background(), []byte("no deadline\n")); err != nil {
+		t.Fatal(err)
+	}
+	if after := conn.Deadlines(); len(after) != 2 {
+		t.Errorf("Expected no further deadlines, got %v", after[2:])
+	}
+}
+
+type traceKey struct{}
+
+func extractTrace(ctx context.Context) map[string]interface{} {
+	if id, ok := ctx.Value(traceKey{}).(string); ok {
+		return map[string]interface{}{"trace_id": id, "source": "context"}
+	}
+	return nil
+}
+
+func TestContextExtractor(t *testing.T) {
+	conn := &fakeConn{}
+	w, _ := newFakeWriter(t, conn)
+	w.ContextExtractor = extractTrace
+
+	ctx := context.WithValue(context.Background(), traceKey{}, "abc123")
+	if _, err := w.LogContext(ctx, "handled"); err != nil {
+		t.Fatal(err)
+	}
+	event := lastEvent(t, conn)
+	if event["trace_id"] != "abc123" {
+		t.Errorf("Expected the trace ID from the context, got %v", event["trace_id"])
+	}
+
+	fields := map[string]interface{}{"source": "caller"}
+	w.LogFieldsContext(ctx, "handled", fields)
+	event = lastEvent(t, conn)
+	if event["source"] != "caller" || event["trace_id"] != "abc123" {
+		t.Errorf("Expected the caller's fields to win over the context's, got %v", event)
+	}
+	if len(fields) != 1 {
+		t.Errorf("Expected the caller's fields to be unchanged, got %v", fields)
+	}
+
+	w.LogContext(context.Background(), "no trace")
+	if _, ok := lastEvent(t, conn)["trace_id"]; ok {
+		t.Error("Expected no trace ID from a context without one")
+	}
+}
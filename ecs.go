@@ -0,0 +1,42 @@
+package logopher
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// ECSVersion is the version of the Elastic Common Schema that ECSTemplate
+// follows, sent as ecs.version
+const ECSVersion = "8.11.0"
+
+// ECSTemplate is a Template which renders messages with the field names and
+// nesting of the Elastic Common Schema, in place of the default envelope:
+//
+//	{"@timestamp":"...","message":"...","host":{"name":"..."},"log":{"level":"info"},"ecs":{"version":"8.11.0"}}
+//
+// The level field added by the leveled helpers becomes log.level, and other
+// fields are sent as they are. A field which would replace one of the keys
+// above is sent with a "fields." prefix, as with the default envelope. An empty
+// host is left out.
+func ECSTemplate(msg, host string, ts time.Time, fields map[string]interface{}) ([]byte, error) {
+	event := map[string]interface{}{
+		"@timestamp": ts.Format(DefaultTimestampFormat),
+		"message":    msg,
+		"ecs":        map[string]interface{}{"version": ECSVersion},
+	}
+	if host != "" {
+		event["host"] = map[string]interface{}{"name": host}
+	}
+	for k, v := range fields {
+		if k == "level" {
+			event["log"] = map[string]interface{}{"level": v}
+			continue
+		}
+		switch k {
+		case "@timestamp", "message", "host", "log", "ecs":
+			k = reservedFieldPrefix + k
+		}
+		event[k] = v
+	}
+	return json.Marshal(event)
+}
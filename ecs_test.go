@@ -0,0 +1,69 @@
+package logopher
+
+import "testing"
+
+func TestECSTemplate(t *testing.T) {
+	conn := &fakeConn{}
+	w, _ := newFakeWriter(t, conn)
+	w.Template = ECSTemplate
+	w.Host = "web-1"
+
+	w.Warn("disk nearly full")
+	event := lastEvent(t, conn)
+	if event["message"] != "disk nearly full" {
+		t.Errorf("Expected the message, got %v", event["message"])
+	}
+	if _, ok := event["@timestamp"].(string); !ok {
+		t.Errorf("Expected a @timestamp, got %v", event["@timestamp"])
+	}
+	host, _ := event["host"].(map[string]interface{})
+	if host["name"] != "web-1" {
+		t.Errorf("Expected host.name to be web-1, got %v", event["host"])
+	}
+	log, _ := event["log"].(map[string]interface{})
+	if log["level"] != "warn" {
+		t.Errorf("Expected log.level to be warn, got %v", event["log"])
+	}
+	ecs, _ := event["ecs"].(map[string]interface{})
+	if ecs["version"] != ECSVersion {
+		t.Errorf("Expected ecs.version to be %s, got %v", ECSVersion, event["ecs"])
+	}
+	for _, legacy := range []string{"level", "@version"} {
+		if _, ok := event[legacy]; ok {
+			t.Errorf("Expected no legacy %s field, got %v", legacy, event[legacy])
+		}
+	}
+}
+
+func TestECSTemplateFields(t *testing.T) {
+	conn := &fakeConn{}
+	w, _ := newFakeWriter(t, conn)
+	w.Template = ECSTemplate
+
+	w.LogFields("request", map[string]interface{}{"http.response.status_code": 200, "host": "spoofed"})
+	event := lastEvent(t, conn)
+	if event["http.response.status_code"] != float64(200) {
+		t.Errorf("Expected the field to be kept, got %v", event["http.response.status_code"])
+	}
+	if event["fields.host"] != "spoofed" {
+		t.Errorf("Expected a field colliding with host to be prefixed, got %v", event["fields.host"])
+	}
+	if _, ok := event["host"].(map[string]interface{}); !ok {
+		t.Errorf("Expected the host object to be intact, got %v", event["host"])
+	}
+	if _, ok := event["log"]; ok {
+		t.Errorf("Expected no log object without a level, got %v", event["log"])
+	}
+}
+
+func TestWithECS(t *testing.T) {
+	server := listenUDP(t)
+	w, err := New(server.LocalAddr().String(), WithECS())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if w.Template == nil {
+		t.Error("Expected WithECS to set the Template")
+	}
+}
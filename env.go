@@ -0,0 +1,47 @@
+package logopher
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// NewFromEnv creates a UDPWriter configured by environment variables whose
+// names begin with prefix and an underscore. With the prefix LOGOPHER they are
+//
+//	LOGOPHER_ADDRESS         the address to send to, which must be set
+//	LOGOPHER_HOST            the Host sent with every message
+//	LOGOPHER_TIMEOUT         the WriteTimeout, as parsed by time.ParseDuration
+//	LOGOPHER_ENABLE_LOGGING  whether to log connection problems, as parsed by
+//	                         strconv.ParseBool
+//
+// Variables which are unset or empty leave the default in place.
+func NewFromEnv(prefix string) (*UDPWriter, error) {
+	name := func(key string) string { return prefix + "_" + key }
+
+	address := os.Getenv(name("ADDRESS"))
+	if address == "" {
+		return nil, fmt.Errorf("logopher: %s is not set", name("ADDRESS"))
+	}
+
+	var opts []Option
+	if host := os.Getenv(name("HOST")); host != "" {
+		opts = append(opts, WithHost(host))
+	}
+	if v := os.Getenv(name("TIMEOUT")); v != "" {
+		timeout, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("logopher: invalid %s: %w", name("TIMEOUT"), err)
+		}
+		opts = append(opts, WithWriteTimeout(timeout))
+	}
+	if v := os.Getenv(name("ENABLE_LOGGING")); v != "" {
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("logopher: invalid %s: %w", name("ENABLE_LOGGING"), err)
+		}
+		opts = append(opts, WithLogging(enabled))
+	}
+	return New(address, opts...)
+}
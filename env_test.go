@@ -0,0 +1,79 @@
+package logopher
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewFromEnv(t *testing.T) {
+	server := listenUDP(t)
+	t.Setenv("TESTLOG_ADDRESS", server.LocalAddr().String())
+	t.Setenv("TESTLOG_HOST", "web-1")
+	t.Setenv("TESTLOG_TIMEOUT", "250ms")
+	t.Setenv("TESTLOG_ENABLE_LOGGING", "true")
+
+	w, err := NewFromEnv("TESTLOG")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	if w.address != server.LocalAddr().String() {
+		t.Errorf("Expected address %s, got %s", server.LocalAddr(), w.address)
+	}
+	if w.Host != "web-1" {
+		t.Errorf("Expected host web-1, got %s", w.Host)
+	}
+	if w.WriteTimeout != 250*time.Millisecond {
+		t.Errorf("Expected a write timeout of 250ms, got %s", w.WriteTimeout)
+	}
+	if !w.enableLogging {
+		t.Error("Expected logging to be enabled")
+	}
+}
+
+func TestNewFromEnvDefaults(t *testing.T) {
+	server := listenUDP(t)
+	t.Setenv("TESTLOG_ADDRESS", server.LocalAddr().String())
+
+	w, err := NewFromEnv("TESTLOG")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	if w.Host != resolveHost() {
+		t.Errorf("Expected host %s, got %s", resolveHost(), w.Host)
+	}
+	if w.WriteTimeout != 0 {
+		t.Errorf("Expected no write timeout, got %s", w.WriteTimeout)
+	}
+	if w.enableLogging {
+		t.Error("Expected logging to be disabled")
+	}
+}
+
+func TestNewFromEnvErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want string
+	}{
+		{"no address", map[string]string{}, "TESTLOG_ADDRESS is not set"},
+		{"bad timeout", map[string]string{"TESTLOG_ADDRESS": "127.0.0.1:1", "TESTLOG_TIMEOUT": "soon"}, "invalid TESTLOG_TIMEOUT"},
+		{"bad logging", map[string]string{"TESTLOG_ADDRESS": "127.0.0.1:1", "TESTLOG_ENABLE_LOGGING": "maybe"}, "invalid TESTLOG_ENABLE_LOGGING"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("TESTLOG_ADDRESS", "")
+			for k, v := range test.env {
+				t.Setenv(k, v)
+			}
+			_, err := NewFromEnv("TESTLOG")
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("Expected an error containing %q, got %v", test.want, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package logopher
+
+import (
+	"sync"
+	"unicode/utf8"
+)
+
+// envelopePool recycles the buffers Log builds payloads in, so that logging a
+// plain message doesn't allocate
+var envelopePool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, 0, 512)
+		return &b
+	},
+}
+
+// maxPooledEnvelope is the largest buffer returned to envelopePool. Holding on
+// to the buffer from one huge message would waste memory.
+const maxPooledEnvelope = 64 << 10
+
+// plain reports whether a message with fields can skip the map and be built by
+// appendEnvelope, because nothing but the envelope keys would be sent
+func (u *baseWriter) plain(fields map[string]interface{}) bool {
+	return len(fields) == 0 && len(u.DefaultFields) == 0 && len(u.processFields) == 0 && !u.AddSource && !u.Sequence && u.Template == nil && u.Redactor == nil
+}
+
+// logPlain sends msg with nothing but the envelope, building the payload in a
+// pooled buffer
+func (u *baseWriter) logPlain(msg string) (int, error) {
+	bp := envelopePool.Get().(*[]byte)
+	b := u.appendEnvelope((*bp)[:0], msg)
+	n, err := u.Write(b)
+	if cap(b) <= maxPooledEnvelope {
+		*bp = b
+		envelopePool.Put(bp)
+	}
+	return n, err
+}
+
+// appendEnvelope appends the payload formatMessage would build for msg with no
+// fields, byte for byte, without going through a map and encoding/json
+func (u *baseWriter) appendEnvelope(dst []byte, msg string) []byte {
+	format := u.TimestampFormat
+	if format == "" {
+		format = DefaultTimestampFormat
+	}
+	// The members go in sorted order, as encoding/json would put them. Since
+	// the timestamp and message keys can be renamed, that order is worked out
+	// each time.
+	keys := u.keys()
+	host := u.host()
+	members := [4]envelopeMember{{keys.timestamp, timestampMember}, {keys.message, messageMember}}
+	n := 2
+	if u.Version != "" {
+		members[n] = envelopeMember{"@version", versionMember}
+		n++
+	}
+	if host != "" {
+		members[n] = envelopeMember{"host", hostMember}
+		n++
+	}
+	for i := 1; i < n; i++ {
+		for j := i; j > 0 && members[j].key < members[j-1].key; j-- {
+			members[j], members[j-1] = members[j-1], members[j]
+		}
+	}
+
+	var scratch [64]byte
+	dst = append(dst, '{')
+	for i, m := range members[:n] {
+		if i > 0 {
+			dst = append(dst, ',')
+		}
+		dst = appendJSONString(dst, m.key)
+		dst = append(dst, ':')
+		switch m.kind {
+		case timestampMember:
+			dst = appendJSONString(dst, u.now().AppendFormat(scratch[:0], format))
+		case versionMember:
+			dst = appendJSONString(dst, u.Version)
+		case hostMember:
+			dst = appendJSONString(dst, host)
+		case messageMember:
+			dst = appendJSONString(dst, msg)
+		}
+	}
+	dst = append(dst, '}')
+	return append(dst, u.Terminator...)
+}
+
+// envelopeMember is one of the fields appendEnvelope writes, and what kind
+type envelopeMember struct {
+	key  string
+	kind int
+}
+
+// The kinds of envelopeMember
+const (
+	timestampMember = iota
+	versionMember
+	hostMember
+	messageMember
+)
+
+const hexDigits = "0123456789abcdef"
+
+// appendJSONString appends s as a quoted JSON string, escaped exactly as
+// encoding/json escapes it: including <, > and &, U+2028 and U+2029, and
+// replacing invalid UTF-8 with U+FFFD
+func appendJSONString[T string | []byte](dst []byte, s T) []byte {
+	dst = append(dst, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if b := s[i]; b < utf8.RuneSelf {
+			if b >= 0x20 && b != '"' && b != '\\' && b != '<' && b != '>' && b != '&' {
+				i++
+				continue
+			}
+			dst = append(dst, s[start:i]...)
+			switch b {
+			case '"', '\\':
+				dst = append(dst, '\\', b)
+			case '\b':
+				dst = append(dst, '\\', 'b')
+			case '\f':
+				dst = append(dst, '\\', 'f')
+			case '\n':
+				dst = append(dst, '\\', 'n')
+			case '\r':
+				dst = append(dst, '\\', 'r')
+			case '\t':
+				dst = append(dst, '\\', 't')
+			default:
+				dst = append(dst, '\\', 'u', '0', '0', hexDigits[b>>4], hexDigits[b&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+		n := len(s) - i
+		if n > utf8.UTFMax {
+			n = utf8.UTFMax
+		}
+		c, size := utf8.DecodeRuneInString(string(s[i : i+n]))
+		if c == utf8.RuneError && size == 1 {
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, "\ufffd"...)
+			i += size
+			start = i
+			continue
+		}
+		if c == '\u2028' || c == '\u2029' {
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, '\\', 'u', '2', '0', '2', hexDigits[c&0xF])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	dst = append(dst, s[start:]...)
+	return append(dst, '"')
+}
@@ -0,0 +1,161 @@
+package logopher
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+)
+
+// envelopeMessages covers everything encoding/json escapes
+var envelopeMessages = []string{
+	"",
+	"plain message",
+	`he said "hi" C:\Windows`,
+	"line one\nline two\r\n\ttabbed\b\f",
+	"bell \x07, null \x00 and del \x7f",
+	"</script><script>alert(1)</script> & more",
+	"unicode: héllo, 世界, 🐹",
+	"separators \u2028 and \u2029",
+	"invalid \xff\xfe utf-8 \xe2\x82",
+}
+
+func TestAppendEnvelopeMatchesFormatMessage(t *testing.T) {
+	w, _ := newFakeWriter(t, &fakeConn{})
+	w.Host = `host "with" <quotes>`
+	// Layouts without any time verbs render the same whenever they're used, so
+	// both payloads can be compared byte for byte. The keys cover every place
+	// the timestamp and message can sort into among the other keys.
+	tests := []struct {
+		version string
+		format  string
+		keys    envelopeKeys
+	}{
+		{DefaultVersion, "fixed", defaultKeys},
+		{"", "fixed", defaultKeys},
+		{"2", `"quoted" <layout> & \ `, defaultKeys},
+		{DefaultVersion, "fixed", envelopeKeys{DefaultTimestampKey, "@body"}},
+		{DefaultVersion, "fixed", envelopeKeys{DefaultTimestampKey, "@text"}},
+		{DefaultVersion, "fixed", envelopeKeys{DefaultTimestampKey, "body"}},
+		{"", "fixed", envelopeKeys{DefaultTimestampKey, "@text"}},
+		{DefaultVersion, "fixed", envelopeKeys{DefaultTimestampKey, `"log" <key>`}},
+		{DefaultVersion, "fixed", envelopeKeys{"time", DefaultMessageKey}},
+		{DefaultVersion, "fixed", envelopeKeys{"a", "b"}},
+		{DefaultVersion, "fixed", envelopeKeys{"z", "log"}},
+		{"", "fixed", envelopeKeys{"timestamp", "@message"}},
+	}
+	for _, test := range tests {
+		w.Version = test.version
+		w.TimestampFormat = test.format
+		w.TimestampKey = test.keys.timestamp
+		w.MessageKey = test.keys.message
+		for _, msg := range envelopeMessages {
+			expected, err := formatMessage(test.keys, test.format, test.version, msg, w.Host, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected = append(expected, '\n')
+			if got := w.appendEnvelope(nil, msg); string(got) != string(expected) {
+				t.Errorf("Expected %s, got %s", expected, got)
+			}
+		}
+	}
+}
+
+func TestAppendEnvelopeTimestamp(t *testing.T) {
+	w, _ := newFakeWriter(t, &fakeConn{})
+	var event map[string]interface{}
+	if err := json.Unmarshal(w.appendEnvelope(nil, "when"), &event); err != nil {
+		t.Fatal(err)
+	}
+	ts, err := time.Parse(time.RFC3339Nano, event["@timestamp"].(string))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(ts) > time.Minute {
+		t.Errorf("Expected the current time, got %s", ts)
+	}
+}
+
+func TestLogFastPathMatchesEncode(t *testing.T) {
+	conn := &fakeConn{}
+	w, _ := newFakeWriter(t, conn)
+	w.TimestampFormat = "fixed"
+	for _, msg := range envelopeMessages {
+		w.Log(msg)
+		writes := conn.Writes()
+		expected, _ := formatMessage(defaultKeys, "fixed", DefaultVersion, msg, w.Host, nil)
+		expected = append(expected, '\n')
+		if got := writes[len(writes)-1]; string(got) != string(expected) {
+			t.Errorf("Expected %s, got %s", expected, got)
+		}
+	}
+}
+
+// discardConn accepts and forgets every write, so benchmarks measure the
+// writer rather than the connection
+type discardConn struct{ fakeConn }
+
+func (*discardConn) Write(b []byte) (int, error) { return len(b), nil }
+
+func newBenchmarkWriter(b *testing.B) *UDPWriter {
+	w := &UDPWriter{baseWriter: newBaseWriter("udp", "bench:5000", false)}
+	w.Dialer = func(network, address string) (net.Conn, error) { return &discardConn{}, nil }
+	if err := w.open(); err != nil {
+		b.Fatal(err)
+	}
+	return w
+}
+
+func BenchmarkLog(b *testing.B) {
+	w := newBenchmarkWriter(b)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		w.Log("request handled in 12ms")
+	}
+}
+
+// BenchmarkLogThroughMap measures the map and encoding/json path that Log
+// takes when there are fields, for comparison with BenchmarkLog
+func BenchmarkLogThroughMap(b *testing.B) {
+	w := newBenchmarkWriter(b)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		data, _ := formatMessage(defaultKeys, w.timestamp(), w.Version, "request handled in 12ms", w.Host, nil)
+		w.Write(data)
+	}
+}
+
+func BenchmarkLogFields(b *testing.B) {
+	w := newBenchmarkWriter(b)
+	fields := map[string]interface{}{"user": "bob", "status": 200}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		w.LogFields("request handled in 12ms", fields)
+	}
+}
+
+func TestEnvelopeFieldOrder(t *testing.T) {
+	conn := &fakeConn{}
+	w, _ := newFakeWriter(t, conn)
+	w.clock = func() time.Time { return time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC) }
+	fields := map[string]interface{}{
+		"zeta": 1, "alpha": 2, "mid": 3, "host": "collides",
+		"nested": map[string]interface{}{"b": 1, "a": 2, "c": 3},
+	}
+
+	w.LogFields("ordered", fields)
+	first := conn.Writes()[0]
+	for i := 0; i < 20; i++ {
+		w.LogFields("ordered", fields)
+		if got := conn.Writes()[i+1]; !bytes.Equal(got, first) {
+			t.Fatalf("Expected every encoding to be identical to\n%s\ngot\n%s", first, got)
+		}
+	}
+	expected := `{"@timestamp":"2024-01-02T03:04:05Z","@version":"1","alpha":2,"fields.host":"collides","host":"` + w.Host +
+		`","message":"ordered","mid":3,"nested":{"a":2,"b":1,"c":3},"zeta":1}` + "\n"
+	if string(first) != expected {
+		t.Errorf("Expected keys in sorted order\n%s\ngot\n%s", expected, first)
+	}
+}
@@ -0,0 +1,52 @@
+package logopher
+
+import "fmt"
+
+// WriteError is returned when a message could not be completely written. Use
+// errors.As to find out how much of it made it onto the connection.
+type WriteError struct {
+	// Written is how many bytes were written before the failure
+	Written int
+	// Expected is how many bytes the message was
+	Expected int
+	// Cause is the error returned by the connection
+	Cause error
+}
+
+func (e *WriteError) Error() string {
+	return fmt.Sprintf("logopher: wrote %d of %d bytes: %s", e.Written, e.Expected, e.Cause)
+}
+
+// Unwrap returns the error returned by the connection
+func (e *WriteError) Unwrap() error {
+	return e.Cause
+}
+
+// CloseError is returned, joined with a WriteError, when the connection could
+// not be closed after a failed write
+type CloseError struct {
+	// Cause is the error returned while closing the connection
+	Cause error
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("logopher: closing connection: %s", e.Cause)
+}
+
+// Unwrap returns the error returned while closing the connection
+func (e *CloseError) Unwrap() error {
+	return e.Cause
+}
+
+// DatagramTooLargeError is returned when a message is larger than a UDP
+// writer's MaxDatagramSize. Nothing is sent.
+type DatagramTooLargeError struct {
+	// Size is how many bytes the message was
+	Size int
+	// Max is the MaxDatagramSize it exceeded
+	Max int
+}
+
+func (e *DatagramTooLargeError) Error() string {
+	return fmt.Sprintf("logopher: %d byte message exceeds the maximum datagram size of %d bytes", e.Size, e.Max)
+}
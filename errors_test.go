@@ -0,0 +1,86 @@
+package logopher
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestWriteErrorAndCloseError(t *testing.T) {
+	writeErr := errors.New("broken pipe")
+	closeErr := errors.New("bad file descriptor")
+	w, _ := newFakeWriter(t, &fakeConn{failWrites: 1, writeErr: writeErr, closeErr: closeErr})
+
+	_, err := w.Write([]byte("hello\n"))
+	var we *WriteError
+	if !errors.As(err, &we) {
+		t.Fatalf("Expected a WriteError, got %v", err)
+	}
+	if we.Written != 0 || we.Expected != 6 {
+		t.Errorf("Expected 0 of 6 bytes written, got %d of %d", we.Written, we.Expected)
+	}
+	var ce *CloseError
+	if !errors.As(err, &ce) {
+		t.Fatalf("Expected a CloseError, got %v", err)
+	}
+	if !errors.Is(err, writeErr) || !errors.Is(err, closeErr) {
+		t.Errorf("Expected both causes to be recoverable, got %v", err)
+	}
+}
+
+func TestWriteErrorWithoutCloseError(t *testing.T) {
+	writeErr := errors.New("broken pipe")
+	w, _ := newFakeWriter(t, &fakeConn{failWrites: 1, writeErr: writeErr})
+
+	_, err := w.Write([]byte("hello\n"))
+	if !errors.Is(err, writeErr) {
+		t.Errorf("Expected %v, got %v", writeErr, err)
+	}
+	var ce *CloseError
+	if errors.As(err, &ce) {
+		t.Errorf("Expected no CloseError, got %v", ce)
+	}
+}
+
+func TestDatagramTooLarge(t *testing.T) {
+	l := listenUDP(t)
+	w, err := DialUDP(l.LocalAddr().String(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if w.MaxDatagramSize != DefaultMaxDatagramSize {
+		t.Errorf("Expected a default MaxDatagramSize of %d, got %d", DefaultMaxDatagramSize, w.MaxDatagramSize)
+	}
+
+	w.MaxDatagramSize = 100
+	n, err := w.Write(make([]byte, 101))
+	var te *DatagramTooLargeError
+	if !errors.As(err, &te) {
+		t.Fatalf("Expected a DatagramTooLargeError, got %v", err)
+	}
+	if te.Size != 101 || te.Max != 100 {
+		t.Errorf("Expected 101 bytes over a limit of 100, got %d over %d", te.Size, te.Max)
+	}
+	if n != 0 {
+		t.Errorf("Expected nothing to be written, got %d bytes", n)
+	}
+	if got := w.Stats().WriteErrors; got != 1 {
+		t.Errorf("Expected 1 write error, got %d", got)
+	}
+
+	if _, err := w.Write(make([]byte, 100)); err != nil {
+		t.Errorf("Expected a payload at the limit to be sent, got %s", err)
+	}
+	if got := len(readDatagram(t, l)); got != 100 {
+		t.Errorf("Expected a 100 byte datagram, got %d", got)
+	}
+}
+
+func TestMaxDatagramSizeIgnoredByStreams(t *testing.T) {
+	conn := &fakeConn{}
+	w, _ := newFakeWriter(t, conn)
+	w.MaxDatagramSize = 10
+	if _, err := w.Write(make([]byte, 100)); err != nil {
+		t.Errorf("Expected a stream writer to ignore MaxDatagramSize, got %s", err)
+	}
+}
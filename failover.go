@@ -0,0 +1,77 @@
+package logopher
+
+import (
+	"errors"
+	"net"
+)
+
+// failoverAfter is how many writes in a row may fail on one address before a
+// failover writer moves on to the next
+const failoverAfter = 3
+
+// NewFailover creates a UDPWriter which sends to the first of addresses, and
+// moves on to the next whenever the current one can't be dialed, or after
+// several writes to it in a row have failed. After the last address it goes
+// back to the first. AutoReconnect is enabled, so that a broken connection is
+// replaced by one to the next address without waiting for a call to Reopen.
+// opts are applied as for New.
+func NewFailover(addresses []string, opts ...Option) (*UDPWriter, error) {
+	if len(addresses) == 0 {
+		return nil, errors.New("logopher: no addresses to fail over between")
+	}
+	normalized := make([]string, len(addresses))
+	for i, address := range addresses {
+		var err error
+		if normalized[i], err = normalizeAddress(address); err != nil {
+			return nil, err
+		}
+	}
+	failover := func(u *baseWriter) {
+		u.addresses = normalized
+		u.AutoReconnect = true
+	}
+	return dialUDP("udp", normalized[0], append([]Option{failover}, opts...))
+}
+
+// ActiveAddress returns the address the writer is currently sending to
+func (u *baseWriter) ActiveAddress() string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.address
+}
+
+// dialFailover tries each of the other addresses in turn, after the current one
+// failed to dial with err. The first to answer becomes the active address. The
+// caller must hold the mutex.
+func (u *baseWriter) dialFailover(err error) (net.Conn, error) {
+	errs := []error{err}
+	failed := u.address
+	for i := 1; i < len(u.addresses); i++ {
+		next := (u.active + i) % len(u.addresses)
+		u.address = u.addresses[next]
+		conn, err := u.connect()
+		if err == nil {
+			u.logf("Failed over from %s to %s", failed, u.address)
+			u.active = next
+			u.failures = 0
+			return conn, nil
+		}
+		errs = append(errs, err)
+	}
+	u.address = failed
+	return nil, errors.Join(errs...)
+}
+
+// writeFailed counts a failed write against the active address, moving on to
+// the next once too many have failed in a row. The caller must hold the mutex.
+func (u *baseWriter) writeFailed() {
+	u.failures++
+	if len(u.addresses) < 2 || u.failures < failoverAfter {
+		return
+	}
+	failed := u.address
+	u.failures = 0
+	u.active = (u.active + 1) % len(u.addresses)
+	u.address = u.addresses[u.active]
+	u.logf("Failing over from %s to %s after %d failed writes", failed, u.address, failoverAfter)
+}
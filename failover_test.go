@@ -0,0 +1,86 @@
+package logopher
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"testing"
+)
+
+// addressDialer hands out connections by address. An address with no
+// connections left refuses to dial.
+type addressDialer struct {
+	mu    sync.Mutex
+	conns map[string][]*fakeConn
+	dials []string
+}
+
+func (d *addressDialer) dial(network, address string) (net.Conn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.dials = append(d.dials, address)
+	if len(d.conns[address]) == 0 {
+		return nil, errors.New("connection refused")
+	}
+	conn := d.conns[address][0]
+	d.conns[address] = d.conns[address][1:]
+	return conn, nil
+}
+
+func TestFailoverWhenPrimaryUnreachable(t *testing.T) {
+	secondary := &fakeConn{}
+	d := &addressDialer{conns: map[string][]*fakeConn{"secondary:5000": {secondary}}}
+	w, err := NewFailover([]string{"primary:5000", "secondary:5000"}, WithDialer(d.dial))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if active := w.ActiveAddress(); active != "secondary:5000" {
+		t.Errorf("Expected secondary:5000 to be active, got %s", active)
+	}
+	if _, err := w.Log("hello"); err != nil {
+		t.Fatal(err)
+	}
+	if len(secondary.Writes()) != 1 {
+		t.Error("Expected the message to be sent to the secondary")
+	}
+}
+
+func TestFailoverAfterRepeatedWriteFailures(t *testing.T) {
+	writeErr := errors.New("connection refused")
+	var primaries []*fakeConn
+	for i := 0; i < failoverAfter; i++ {
+		primaries = append(primaries, &fakeConn{failWrites: 1, writeErr: writeErr})
+	}
+	secondary := &fakeConn{}
+	d := &addressDialer{conns: map[string][]*fakeConn{
+		"primary:5000":   primaries,
+		"secondary:5000": {secondary},
+	}}
+	w, err := NewFailover([]string{"primary:5000", "secondary:5000"}, WithDialer(d.dial), WithMaxRetries(failoverAfter))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := w.Write([]byte("hello\n")); err != nil {
+		t.Fatal(err)
+	}
+	if active := w.ActiveAddress(); active != "secondary:5000" {
+		t.Errorf("Expected secondary:5000 to be active, got %s", active)
+	}
+	if writes := secondary.Writes(); len(writes) != 1 || string(writes[0]) != "hello\n" {
+		t.Errorf("Expected the message to be retried on the secondary, got %q", writes)
+	}
+}
+
+func TestFailoverAllUnreachable(t *testing.T) {
+	d := &addressDialer{}
+	if _, err := NewFailover([]string{"primary:5000", "secondary:5000"}, WithDialer(d.dial)); err == nil {
+		t.Error("Expected an error when no address can be dialed")
+	}
+	if len(d.dials) != 2 {
+		t.Errorf("Expected both addresses to be tried, got %v", d.dials)
+	}
+	if _, err := NewFailover(nil); err == nil {
+		t.Error("Expected an error with no addresses")
+	}
+}
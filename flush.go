@@ -0,0 +1,32 @@
+package logopher
+
+// Flusher is implemented by writers which can hold on to messages before
+// sending them, such as BatchWriter and AsyncWriter. Flush sends everything
+// buffered when it is called, and returns any error from writing it.
+type Flusher interface {
+	Flush() error
+}
+
+var (
+	_ Flusher = (*UDPWriter)(nil)
+	_ Flusher = (*TCPWriter)(nil)
+	_ Flusher = (*TLSWriter)(nil)
+	_ Flusher = (*UnixWriter)(nil)
+	_ Flusher = (*BeatsWriter)(nil)
+	_ Flusher = (*BatchWriter)(nil)
+	_ Flusher = (*AsyncWriter)(nil)
+	_ Flusher = (*BreakerWriter)(nil)
+)
+
+// Flush does nothing, since every Write is sent immediately
+func (u *baseWriter) Flush() error {
+	return nil
+}
+
+// flushWriter flushes w, if it buffers messages
+func flushWriter(w Writer) error {
+	if f, ok := w.(Flusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
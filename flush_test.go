@@ -0,0 +1,115 @@
+package logopher
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestBatchWriterFlush(t *testing.T) {
+	r := &recordingWriter{}
+	b := NewBatchWriter(r, 100, 1<<16, 0)
+	defer b.Close()
+
+	b.Log("one")
+	b.Log("two")
+	if len(r.Payloads()) != 0 {
+		t.Fatal("Expected the messages to be batched")
+	}
+	if err := b.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if payloads := r.Payloads(); len(payloads) != 1 {
+		t.Fatalf("Expected 1 batch, got %d", len(payloads))
+	}
+	if err := b.Flush(); err != nil {
+		t.Errorf("Expected flushing an empty batch to succeed, got %s", err)
+	}
+	if payloads := r.Payloads(); len(payloads) != 1 {
+		t.Errorf("Expected flushing an empty batch to write nothing, got %d batches", len(payloads))
+	}
+}
+
+func TestBatchWriterFlushError(t *testing.T) {
+	writeErr := errors.New("boom")
+	r := &recordingWriter{writeErr: writeErr}
+	b := NewBatchWriter(r, 100, 1<<16, 0)
+	defer b.Close()
+
+	b.Log("lost")
+	if err := b.Flush(); !errors.Is(err, writeErr) {
+		t.Errorf("Expected %v, got %v", writeErr, err)
+	}
+}
+
+func TestAsyncWriterFlush(t *testing.T) {
+	gate := make(chan struct{})
+	r := &recordingWriter{gate: gate}
+	a := NewAsyncWriter(r, 10)
+	defer a.Close()
+
+	expected := []string{"one", "two", "three"}
+	for _, msg := range expected {
+		if _, err := a.Log(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	flushed := make(chan error)
+	go func() { flushed <- a.Flush() }()
+	select {
+	case <-flushed:
+		t.Fatal("Expected Flush to wait for the stalled writer")
+	default:
+	}
+
+	close(gate)
+	if err := <-flushed; err != nil {
+		t.Fatal(err)
+	}
+	if got := r.Messages(t); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+}
+
+func TestAsyncWriterFlushError(t *testing.T) {
+	writeErr := errors.New("boom")
+	r := &recordingWriter{writeErr: writeErr}
+	a := NewAsyncWriter(NewBatchWriter(r, 100, 1<<16, 0), 10)
+	defer a.Close()
+
+	a.Log("lost")
+	if err := a.Flush(); !errors.Is(err, writeErr) {
+		t.Errorf("Expected %v, got %v", writeErr, err)
+	}
+}
+
+func TestAsyncWriterFlushesBatches(t *testing.T) {
+	r := &recordingWriter{}
+	a := NewAsyncWriter(NewBatchWriter(r, 100, 1<<16, 0), 10)
+	defer a.Close()
+
+	a.Log("batched")
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if got := r.Messages(t); !reflect.DeepEqual(got, []string{"batched"}) {
+		t.Errorf("Expected Flush to reach through to the batch, got %v", got)
+	}
+}
+
+func TestAsyncWriterFlushAfterClose(t *testing.T) {
+	a := NewAsyncWriter(&recordingWriter{}, 10)
+	a.Close()
+	if err := a.Flush(); err != ErrClosed {
+		t.Errorf("Expected ErrClosed, got %v", err)
+	}
+}
+
+func TestFlushIsANoOpForSockets(t *testing.T) {
+	conn := &fakeConn{}
+	w, _ := newFakeWriter(t, conn)
+	if err := w.Flush(); err != nil {
+		t.Errorf("Expected nil, got %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+package logopher
+
+import (
+	"bytes"
+	"compress/gzip"
+	"sync"
+)
+
+var _ FieldLogger = (*GzipWriter)(nil)
+
+// GzipWriter wraps a Writer and compresses every payload before it is written,
+// for use with a LogStash pipeline that decompresses its input, such as one
+// using the gzip_lines codec. Each write is a complete gzip member, and a
+// stream of concatenated members decompresses to the original messages.
+//
+// Because a receiver can't reassemble a gzip stream from datagrams that may be
+// lost or reordered, GzipWriter is meant for the TCP and TLS transports. Place
+// a BatchWriter in front of it so that many messages share each compressed
+// payload.
+type GzipWriter struct {
+	writer Writer
+
+	mu  sync.Mutex
+	buf bytes.Buffer
+	gz  *gzip.Writer
+}
+
+// NewGzipWriter creates a GzipWriter in front of w
+func NewGzipWriter(w Writer) *GzipWriter {
+	g := &GzipWriter{writer: w}
+	g.gz = gzip.NewWriter(&g.buf)
+	return g
+}
+
+// Log compresses and writes msg. The byte count returned is the size of the
+// payload before compression.
+func (g *GzipWriter) Log(msg string) (int, error) {
+	return g.LogFields(msg, nil)
+}
+
+// LogFields compresses and writes msg and fields. The byte count returned is
+// the size of the payload before compression.
+func (g *GzipWriter) LogFields(msg string, fields map[string]interface{}) (int, error) {
+	data, err := g.encode(msg, fields)
+	if err != nil {
+		return 0, unlessSampledOut(err)
+	}
+	return g.Write(data)
+}
+
+// Write compresses rawBytes and writes the result. On success, it reports
+// len(rawBytes) bytes written.
+func (g *GzipWriter) Write(rawBytes []byte) (int, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.buf.Reset()
+	g.gz.Reset(&g.buf)
+	if _, err := g.gz.Write(rawBytes); err != nil {
+		return 0, err
+	}
+	if err := g.gz.Close(); err != nil {
+		return 0, err
+	}
+	if _, err := g.writer.Write(g.buf.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(rawBytes), nil
+}
+
+// encode builds payloads the same way the wrapped writer would
+func (g *GzipWriter) encode(msg string, fields map[string]interface{}) ([]byte, error) {
+	return encodeFor(g.writer, msg, fields)
+}
+
+// Reopen re-establishes the wrapped writer's connection
+func (g *GzipWriter) Reopen() error {
+	return g.writer.Reopen()
+}
+
+// Close closes the wrapped writer
+func (g *GzipWriter) Close() error {
+	return g.writer.Close()
+}
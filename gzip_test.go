@@ -0,0 +1,72 @@
+package logopher
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+	"testing"
+)
+
+// gunzip decompresses a stream of concatenated gzip members
+func gunzip(t *testing.T, compressed []byte) string {
+	r, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestGzipWriterRoundTrip(t *testing.T) {
+	r := &recordingWriter{}
+	g := NewGzipWriter(r)
+
+	lines := []string{"first event\n", "second event\n", strings.Repeat("compressible ", 100) + "\n"}
+	for _, line := range lines {
+		n, err := g.Write([]byte(line))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != len(line) {
+			t.Errorf("Expected %d, got %d", len(line), n)
+		}
+	}
+
+	payloads := r.Payloads()
+	if len(payloads) != len(lines) {
+		t.Fatalf("Expected %d payloads, got %d", len(lines), len(payloads))
+	}
+	if len(payloads[2]) >= len(lines[2]) {
+		t.Errorf("Expected the repetitive payload to shrink, got %d bytes from %d", len(payloads[2]), len(lines[2]))
+	}
+	if got := gunzip(t, bytes.Join(payloads, nil)); got != strings.Join(lines, "") {
+		t.Errorf("Round trip produced %q", got)
+	}
+}
+
+func TestGzipWriterBehindBatchWriter(t *testing.T) {
+	r := &recordingWriter{}
+	b := NewBatchWriter(NewGzipWriter(r), 3, 1<<16, 0)
+
+	for _, msg := range []string{"a", "b", "c"} {
+		if _, err := b.Log(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	payloads := r.Payloads()
+	if len(payloads) != 1 {
+		t.Fatalf("Expected the batch to be compressed as one payload, got %d", len(payloads))
+	}
+	events := strings.Split(strings.TrimSuffix(gunzip(t, payloads[0]), "\n"), "\n")
+	if len(events) != 3 {
+		t.Errorf("Expected 3 newline delimited events, got %q", events)
+	}
+}
@@ -0,0 +1,53 @@
+package logopher
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrIdle is the cause OnReconnect is given when a connection is replaced for
+// having gone IdleReconnect without a write
+var ErrIdle = errors.New("logopher: connection was idle")
+
+// touch records that the connection was just used, and arms the idle timer to
+// go off IdleReconnect from now. The caller must hold the mutex.
+func (u *baseWriter) touch() {
+	if u.IdleReconnect <= 0 {
+		return
+	}
+	u.lastActive = time.Now()
+	if u.idleTimer == nil {
+		u.idleTimer = time.AfterFunc(u.IdleReconnect, u.idle)
+		return
+	}
+	u.idleTimer.Reset(u.IdleReconnect)
+}
+
+// idle replaces the connection if it has gone IdleReconnect without a write. A
+// write may have landed while the timer was waiting for the mutex, in which
+// case the timer is put back for whatever is left of the period.
+func (u *baseWriter) idle() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.socket == nil || u.IdleReconnect <= 0 {
+		// The writer was closed, or the connection already lost, and whatever
+		// opens the next one will arm the timer again
+		return
+	}
+	if wait := u.IdleReconnect - time.Since(u.lastActive); wait > 0 {
+		u.idleTimer.Reset(wait)
+		return
+	}
+	u.logf("Reconnecting to %s after %s without a write", u.address, u.IdleReconnect)
+	u.close()
+	if err := u.reconnect(ErrIdle); err != nil {
+		u.logf("Failed to reconnect to %s after it went idle. Underlying error: %s", u.address, err)
+	}
+}
+
+// stopIdle disarms the idle timer. The caller must hold the mutex.
+func (u *baseWriter) stopIdle() {
+	if u.idleTimer != nil {
+		u.idleTimer.Stop()
+	}
+}
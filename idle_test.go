@@ -0,0 +1,66 @@
+package logopher
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIdleReconnect(t *testing.T) {
+	first, second := &fakeConn{}, &fakeConn{}
+	w, d := newFakeWriter(t, first, second)
+	causes := make(chan error, 10)
+	w.OnReconnect = func(err error) { causes <- err }
+	WithIdleReconnect(100 * time.Millisecond)(&w.baseWriter)
+
+	// Writes more often than the idle period keep the connection
+	for i := 0; i < 5; i++ {
+		if _, err := w.Write([]byte("busy\n")); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	select {
+	case err := <-causes:
+		t.Fatalf("Expected no reconnect while writes keep coming, got one for %v", err)
+	default:
+	}
+
+	select {
+	case err := <-causes:
+		if err != ErrIdle {
+			t.Errorf("Expected ErrIdle as the cause, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected a reconnect once the connection went idle")
+	}
+	d.mu.Lock()
+	dials := d.dials
+	d.mu.Unlock()
+	first.mu.Lock()
+	closed := first.closed
+	first.mu.Unlock()
+	if dials != 2 || !closed {
+		t.Errorf("Expected the idle connection to be closed and replaced, got %d dials", dials)
+	}
+	if _, err := w.Write([]byte("after\n")); err != nil {
+		t.Fatal(err)
+	}
+	if len(second.Writes()) != 1 {
+		t.Errorf("Expected the next write on the new connection, got %d", len(second.Writes()))
+	}
+}
+
+func TestIdleReconnectStopsOnClose(t *testing.T) {
+	w, d := newFakeWriter(t, &fakeConn{}, &fakeConn{})
+	w.IdleReconnect = 10 * time.Millisecond
+	w.Write([]byte("once\n"))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.dials != 1 {
+		t.Errorf("Expected no reconnect after Close, got %d dials", d.dials)
+	}
+}
@@ -0,0 +1,58 @@
+package logopher
+
+import (
+	"errors"
+	"net"
+	"time"
+)
+
+// errNoKeepAlive is returned when the connection isn't tcp, and so has no
+// keepalive to configure
+var errNoKeepAlive = errors.New("logopher: connection does not support tcp keepalive")
+
+// SetKeepAlive configures tcp keepalive probes on the connection, so that a
+// peer which disappeared, or a firewall which silently dropped an idle
+// connection, is noticed before the next message is lost to it. A positive
+// period sends probes that often, zero enables probes at the operating
+// system's default interval, and a negative period disables them. The setting
+// is kept, and applied again whenever the connection is reopened. While the
+// writer isn't connected, it is only kept.
+func (u *baseWriter) SetKeepAlive(period time.Duration) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.keepAlive = &period
+	if u.socket == nil {
+		return nil
+	}
+	return setKeepAlive(u.socket, period)
+}
+
+// WithKeepAlive configures tcp keepalive probes with the given period on every
+// connection, including the first, as SetKeepAlive does.
+func WithKeepAlive(period time.Duration) Option {
+	return func(u *baseWriter) {
+		u.keepAlive = &period
+	}
+}
+
+// setKeepAlive configures keepalive on conn, or on the connection beneath it
+// for tls
+func setKeepAlive(conn net.Conn, period time.Duration) error {
+	if nc, ok := conn.(interface{ NetConn() net.Conn }); ok {
+		conn = nc.NetConn()
+	}
+	tcp, ok := conn.(*net.TCPConn)
+	if !ok {
+		return errNoKeepAlive
+	}
+	if period < 0 {
+		return tcp.SetKeepAlive(false)
+	}
+	if err := tcp.SetKeepAlive(true); err != nil {
+		return err
+	}
+	if period > 0 {
+		return tcp.SetKeepAlivePeriod(period)
+	}
+	return nil
+}
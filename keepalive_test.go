@@ -0,0 +1,89 @@
+package logopher
+
+import (
+	"crypto/tls"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSetKeepAlive(t *testing.T) {
+	l, _ := listenTCP(t)
+	w, err := DialTCP(l.Addr().String(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	for _, period := range []time.Duration{30 * time.Second, 0, -1} {
+		if err := w.SetKeepAlive(period); err != nil {
+			t.Errorf("%s: %s", period, err)
+		}
+	}
+	if err := w.SetKeepAlive(time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	if w.keepAlive == nil || *w.keepAlive != time.Minute {
+		t.Error("Expected the period to be kept for the next connection")
+	}
+}
+
+func TestSetKeepAliveTLS(t *testing.T) {
+	cert, pool := selfSignedCert(t)
+	l, _ := listenTLS(t, cert)
+	w, err := DialTLS(l.Addr().String(), &tls.Config{RootCAs: pool}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if err := w.SetKeepAlive(30 * time.Second); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSetKeepAliveUnsupported(t *testing.T) {
+	l := listenUDP(t)
+	w, err := DialUDP(l.LocalAddr().String(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if err := w.SetKeepAlive(30 * time.Second); err != errNoKeepAlive {
+		t.Errorf("Expected errNoKeepAlive, got %v", err)
+	}
+}
+
+func TestWithKeepAlive(t *testing.T) {
+	l, _ := listenTCP(t)
+	captured := &captureLogger{}
+	w, err := NewTCP(l.Addr().String(), WithKeepAlive(time.Minute), WithLogging(true), WithLogger(captured))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if w.keepAlive == nil || *w.keepAlive != time.Minute {
+		t.Error("Expected the period to be kept")
+	}
+	if len(captured.lines) != 0 {
+		t.Errorf("Expected keepalive to be configured cleanly, got %q", captured.lines)
+	}
+}
+
+func TestWithKeepAliveAppliesToFirstConnection(t *testing.T) {
+	captured := &captureLogger{}
+	fake := func(u *baseWriter) {
+		u.Dialer = func(network, address string) (net.Conn, error) { return &fakeConn{}, nil }
+	}
+	w, err := NewTCP("fake:5000", fake, WithKeepAlive(time.Minute), WithLogging(true), WithLogger(captured))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if len(captured.lines) != 1 || !strings.Contains(captured.lines[0], "keepalive") {
+		t.Errorf("Expected keepalive to be attempted on the first connection, got %q", captured.lines)
+	}
+}
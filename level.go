@@ -0,0 +1,102 @@
+package logopher
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// Level is the severity of a message, sent as its level field
+type Level int
+
+// The supported levels, from least to most severe
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+// String returns the name sent in the level field
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	}
+	return fmt.Sprintf("level(%d)", int(l))
+}
+
+// Debug logs msg at LevelDebug
+func (u *baseWriter) Debug(msg string) (int, error) {
+	return u.logLevel(LevelDebug, msg)
+}
+
+// Info logs msg at LevelInfo
+func (u *baseWriter) Info(msg string) (int, error) {
+	return u.logLevel(LevelInfo, msg)
+}
+
+// Warn logs msg at LevelWarn
+func (u *baseWriter) Warn(msg string) (int, error) {
+	return u.logLevel(LevelWarn, msg)
+}
+
+// Error logs msg at LevelError
+func (u *baseWriter) Error(msg string) (int, error) {
+	return u.logLevel(LevelError, msg)
+}
+
+// ErrorField and StackField are the fields LogError sends the error and its
+// stack trace in
+const (
+	ErrorField = "error"
+	StackField = "stack"
+)
+
+// LogError logs msg at LevelError, with err's message in the error field. If
+// err, or any error it wraps, has a StackTrace method, as errors created by
+// github.com/pkg/errors do, the trace is formatted with %+v and sent in the
+// stack field. A nil err is logged like Error.
+func (u *baseWriter) LogError(err error, msg string) (int, error) {
+	if LevelError < u.MinLevel {
+		return 0, nil
+	}
+	fields := map[string]interface{}{"level": LevelError.String()}
+	if err != nil {
+		fields[ErrorField] = err.Error()
+		if stack, ok := stackTrace(err); ok {
+			fields[StackField] = stack
+		}
+	}
+	return u.LogFields(msg, fields)
+}
+
+// stackTrace finds the first error in err's chain with a StackTrace method, and
+// formats its trace. The method is found by name, rather than through an
+// interface, since its result type belongs to whichever package created the
+// error.
+func stackTrace(err error) (string, bool) {
+	for ; err != nil; err = errors.Unwrap(err) {
+		method := reflect.ValueOf(err).MethodByName("StackTrace")
+		if !method.IsValid() || method.Type().NumIn() != 0 || method.Type().NumOut() != 1 {
+			continue
+		}
+		return fmt.Sprintf("%+v", method.Call(nil)[0].Interface()), true
+	}
+	return "", false
+}
+
+// logLevel logs msg with a level field, unless level is below MinLevel, in
+// which case nothing is sent and no error is returned
+func (u *baseWriter) logLevel(level Level, msg string) (int, error) {
+	if level < u.MinLevel {
+		return 0, nil
+	}
+	return u.LogFields(msg, map[string]interface{}{"level": level.String()})
+}
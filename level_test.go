@@ -0,0 +1,120 @@
+package logopher
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestLevelHelpers(t *testing.T) {
+	conn := &fakeConn{}
+	w, _ := newFakeWriter(t, conn)
+
+	helpers := map[string]func(string) (int, error){
+		"debug": w.Debug,
+		"info":  w.Info,
+		"warn":  w.Warn,
+		"error": w.Error,
+	}
+	for level, helper := range helpers {
+		if _, err := helper("leveled"); err != nil {
+			t.Fatal(err)
+		}
+		if event := lastEvent(t, conn); event["level"] != level {
+			t.Errorf("Expected level %s, got %v", level, event["level"])
+		}
+	}
+}
+
+func TestMinLevel(t *testing.T) {
+	conn := &fakeConn{}
+	w, _ := newFakeWriter(t, conn)
+	w.MinLevel = LevelWarn
+
+	for _, helper := range []func(string) (int, error){w.Debug, w.Info} {
+		n, err := helper("too quiet")
+		if n != 0 || err != nil {
+			t.Errorf("Expected (0, nil) for a dropped message, got (%d, %v)", n, err)
+		}
+	}
+	if len(conn.Writes()) != 0 {
+		t.Fatal("Expected messages below MinLevel to be dropped")
+	}
+
+	w.Warn("loud enough")
+	w.Error("louder")
+	if writes := conn.Writes(); len(writes) != 2 {
+		t.Errorf("Expected 2 messages at or above MinLevel, got %d", len(writes))
+	}
+}
+
+func TestLevelString(t *testing.T) {
+	if s := Level(42).String(); s != "level(42)" {
+		t.Errorf("Expected level(42), got %s", s)
+	}
+}
+
+// stackFrames stands in for github.com/pkg/errors.StackTrace, which only
+// prints its frames when formatted with %+v
+type stackFrames []string
+
+func (s stackFrames) Format(f fmt.State, verb rune) {
+	if f.Flag('+') {
+		fmt.Fprint(f, strings.Join(s, "\n"))
+	}
+}
+
+type stackError struct {
+	msg    string
+	frames stackFrames
+}
+
+func (e *stackError) Error() string           { return e.msg }
+func (e *stackError) StackTrace() stackFrames { return e.frames }
+
+func TestLogError(t *testing.T) {
+	conn := &fakeConn{}
+	w, _ := newFakeWriter(t, conn)
+
+	w.LogError(errors.New("disk full"), "save failed")
+	event := lastEvent(t, conn)
+	if event["message"] != "save failed" || event["level"] != "error" {
+		t.Errorf("Expected an error level message, got %v", event)
+	}
+	if event[ErrorField] != "disk full" {
+		t.Errorf("Expected the error field to be disk full, got %v", event[ErrorField])
+	}
+	if _, ok := event[StackField]; ok {
+		t.Error("Expected no stack field for an error without a trace")
+	}
+}
+
+func TestLogErrorStackTrace(t *testing.T) {
+	conn := &fakeConn{}
+	w, _ := newFakeWriter(t, conn)
+
+	cause := &stackError{msg: "disk full", frames: stackFrames{"main.save\n\tmain.go:10", "main.main\n\tmain.go:3"}}
+	w.LogError(fmt.Errorf("saving: %w", cause), "save failed")
+	event := lastEvent(t, conn)
+	if event[ErrorField] != "saving: disk full" {
+		t.Errorf("Expected the whole error message, got %v", event[ErrorField])
+	}
+	expected := "main.save\n\tmain.go:10\nmain.main\n\tmain.go:3"
+	if event[StackField] != expected {
+		t.Errorf("Expected the wrapped error's trace %q, got %q", expected, event[StackField])
+	}
+}
+
+func TestLogErrorMinLevel(t *testing.T) {
+	conn := &fakeConn{}
+	w, _ := newFakeWriter(t, conn)
+	w.MinLevel = Level(LevelError + 1)
+
+	if n, err := w.LogError(errors.New("ignored"), "too quiet"); n != 0 || err != nil {
+		t.Errorf("Expected (0, nil) for a dropped message, got (%d, %v)", n, err)
+	}
+	if len(conn.Writes()) != 0 {
+		t.Error("Expected the message to be dropped")
+	}
+}
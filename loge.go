@@ -0,0 +1,27 @@
+package logopher
+
+import "context"
+
+// LogE is Log, for callers who only care whether it succeeded
+func (u *baseWriter) LogE(msg string) error {
+	_, err := u.Log(msg)
+	return err
+}
+
+// LogFieldsE is LogFields, for callers who only care whether it succeeded
+func (u *baseWriter) LogFieldsE(msg string, fields map[string]interface{}) error {
+	_, err := u.LogFields(msg, fields)
+	return err
+}
+
+// LogContextE is LogContext, for callers who only care whether it succeeded
+func (u *baseWriter) LogContextE(ctx context.Context, msg string) error {
+	_, err := u.LogContext(ctx, msg)
+	return err
+}
+
+// WriteE is Write, for callers who only care whether it succeeded
+func (u *baseWriter) WriteE(rawBytes []byte) error {
+	_, err := u.Write(rawBytes)
+	return err
+}
@@ -0,0 +1,54 @@
+package logopher
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestErrorOnlyHelpers(t *testing.T) {
+	fields := map[string]interface{}{"k": "v"}
+	tests := []struct {
+		name    string
+		helper  func(w *UDPWriter) error
+		wrapped func(w *UDPWriter) (int, error)
+	}{
+		{"LogE",
+			func(w *UDPWriter) error { return w.LogE("hello") },
+			func(w *UDPWriter) (int, error) { return w.Log("hello") }},
+		{"LogFieldsE",
+			func(w *UDPWriter) error { return w.LogFieldsE("hello", fields) },
+			func(w *UDPWriter) (int, error) { return w.LogFields("hello", fields) }},
+		{"LogContextE",
+			func(w *UDPWriter) error { return w.LogContextE(context.Background(), "hello") },
+			func(w *UDPWriter) (int, error) { return w.LogContext(context.Background(), "hello") }},
+		{"WriteE",
+			func(w *UDPWriter) error { return w.WriteE([]byte("hello\n")) },
+			func(w *UDPWriter) (int, error) { return w.Write([]byte("hello\n")) }},
+	}
+	for _, test := range tests {
+		conn := &fakeConn{}
+		w, _ := newFakeWriter(t, conn)
+		if err := test.helper(w); err != nil {
+			t.Errorf("Expected %s to succeed, got %s", test.name, err)
+		}
+		if len(conn.Writes()) != 1 {
+			t.Errorf("Expected %s to write once, got %d writes", test.name, len(conn.Writes()))
+		}
+
+		// On a broken connection, each fails exactly as the method it wraps
+		broken := func() *UDPWriter {
+			w, _ := newFakeWriter(t, &fakeConn{failWrites: 1, writeErr: errors.New("broken pipe")})
+			return w
+		}
+		var expected, got *WriteError
+		_, wrappedErr := test.wrapped(broken())
+		err := test.helper(broken())
+		if !errors.As(wrappedErr, &expected) || !errors.As(err, &got) {
+			t.Fatalf("Expected both %s and the method it wraps to fail with a WriteError, got %v and %v", test.name, err, wrappedErr)
+		}
+		if got.Written != expected.Written || got.Cause.Error() != expected.Cause.Error() {
+			t.Errorf("Expected %s to fail with %v, got %v", test.name, wrappedErr, err)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package logopher
+
+import "log"
+
+// Logger receives the writer's own diagnostics, such as failed writes and
+// reconnects. *log.Logger satisfies it, as do most structured loggers' sugared
+// forms.
+type Logger interface {
+	Printf(format string, args ...interface{})
+}
+
+// logf reports a diagnostic to the Logger if one is set, or to the standard
+// logger if logging was enabled when the writer was created. Otherwise it is
+// discarded.
+func (u *baseWriter) logf(format string, args ...interface{}) {
+	switch {
+	case u.Logger != nil:
+		u.Logger.Printf(format, args...)
+	case u.enableLogging:
+		log.Printf(format, args...)
+	}
+}
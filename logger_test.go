@@ -0,0 +1,64 @@
+package logopher
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// captureLogger records every diagnostic passed to it
+type captureLogger struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (c *captureLogger) Printf(format string, args ...interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lines = append(c.lines, fmt.Sprintf(format, args...))
+}
+
+func TestLoggerReceivesDiagnostics(t *testing.T) {
+	var global bytes.Buffer
+	log.SetOutput(&global)
+	defer log.SetOutput(os.Stderr)
+
+	captured := &captureLogger{}
+	w, _ := newFakeWriter(t, &fakeConn{failWrites: 1, writeErr: errors.New("broken pipe")})
+	w.enableLogging = true
+	w.Logger = captured
+
+	if _, err := w.Write([]byte("hello\n")); err == nil {
+		t.Fatal("Expected the write to fail")
+	}
+	if len(captured.lines) == 0 || !strings.Contains(captured.lines[0], "broken pipe") {
+		t.Errorf("Expected the failure to be reported to the Logger, got %q", captured.lines)
+	}
+	if global.Len() != 0 {
+		t.Errorf("Expected nothing on the standard logger, got %q", global.String())
+	}
+}
+
+func TestLoggingDisabledIsSilent(t *testing.T) {
+	var global bytes.Buffer
+	log.SetOutput(&global)
+	defer log.SetOutput(os.Stderr)
+
+	w, _ := newFakeWriter(t, &fakeConn{failWrites: 1, writeErr: errors.New("broken pipe")})
+	w.Write([]byte("hello\n"))
+	if global.Len() != 0 {
+		t.Errorf("Expected nothing logged, got %q", global.String())
+	}
+
+	w, _ = newFakeWriter(t, &fakeConn{failWrites: 1, writeErr: errors.New("broken pipe")})
+	w.enableLogging = true
+	w.Write([]byte("hello\n"))
+	if !strings.Contains(global.String(), "broken pipe") {
+		t.Errorf("Expected the standard logger to be used when logging is enabled, got %q", global.String())
+	}
+}
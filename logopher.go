@@ -1,85 +1,995 @@
-// Package logopher provides a way to communicate with LogStash over UDP
+// Package logopher provides a way to communicate with LogStash over UDP, TCP, TLS or a
+// unix domain socket, or with its beats input using the Lumberjack protocol
 package logopher
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"io"
 	"net"
+	"net/url"
 	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 )
 
-const basicMessageFormat = "{\"@timestamp\":\"%s\", \"@version\":\"2\", \"message\":\"%s\", \"host\":\"%s\"}\n"
+// DefaultTimestampFormat is the layout used for the @timestamp field unless a
+// writer is configured otherwise. LogStash's date filter understands it natively.
+const DefaultTimestampFormat = time.RFC3339Nano
+
+// Writer is the behavior shared by every Logopher transport. Programming against
+// it, rather than a concrete type such as *UDPWriter, allows the transport to be
+// swapped out, or replaced with a fake in tests.
+type Writer interface {
+	// Log wraps msg in the LogStash JSON envelope and writes it
+	Log(msg string) (int, error)
+	// Write writes pre-formatted bytes to LogStash as-is
+	Write(rawBytes []byte) (int, error)
+	// Close closes the connection to the remote endpoint
+	Close() error
+	// Reopen closes and re-establishes the connection to the remote endpoint
+	Reopen() error
+}
+
+// FieldLogger is a Writer which can also attach structured fields to a message.
+// Every Logopher transport is a FieldLogger.
+type FieldLogger interface {
+	Writer
+	// LogFields wraps msg and fields in the LogStash JSON envelope and writes it
+	LogFields(msg string, fields map[string]interface{}) (int, error)
+}
+
+var _ FieldLogger = (*UDPWriter)(nil)
+
+// baseWriter holds the connection handling and error handling shared by every
+// transport. Each transport embeds it and supplies the network to dial. The
+// mutex guards the socket, so a single writer can be shared by many goroutines
+// without writes interleaving or racing with a reconnect.
+type baseWriter struct {
+	mu            sync.Mutex
+	socket        net.Conn
+	network       string
+	address       string
+	enableLogging bool
+	datagram      bool
+	stats         counters
+	// lastErr is the most recent error from dialing or writing
+	lastErr error
+	// clock tells the time messages are stamped with. It is time.Now, except
+	// in tests which need a timestamp they can predict.
+	clock func() time.Time
+	// opened is whether a connection has ever been established, so that later
+	// ones count as reconnects
+	opened bool
+	// closed is set by Close and cleared by Reopen. While it is set, writes
+	// fail with ErrClosed rather than reconnecting.
+	closed bool
+	// sendBufferSize, if positive, is applied to every new connection
+	sendBufferSize int
+	// addresses, if there is more than one, are failed over between, and active
+	// is the index of the one in use. failures counts the writes in a row which
+	// have failed on it.
+	addresses []string
+	active    int
+	failures  int
+	// keepAlive, if set, is the tcp keepalive period applied to every new
+	// connection
+	keepAlive *time.Duration
+	// dialer, if set, is the net.Dialer behind the Dialer, configured by
+	// options such as WithDialTimeout
+	dialer *net.Dialer
+	// verifyOnDial makes the constructor Ping the endpoint before returning
+	verifyOnDial bool
+	// hostErr is why the hostname lookup failed, if it did. It is reported once,
+	// when the first connection is opened, so options can enable logging first.
+	hostErr error
+	// proxy, if set, is the HTTP proxy tcp connections are tunneled through
+	proxy *url.URL
+	// idleTimer goes off once the connection has gone IdleReconnect without a
+	// write since lastActive
+	idleTimer  *time.Timer
+	lastActive time.Time
+	// processFields, such as the pid, are set by options and sent beneath the
+	// DefaultFields
+	processFields map[string]interface{}
+	// upgrade, if set, is applied to each freshly dialed connection, such as to
+	// perform a tls handshake
+	upgrade func(net.Conn) (net.Conn, error)
+
+	// Dialer is used to establish every connection, which allows routing
+	// through a proxy, or substituting a fake connection in tests. It defaults
+	// to net.Dial. Changing it affects the next Reopen.
+	Dialer func(network, address string) (net.Conn, error)
+
+	// Logger, if set, receives the writer's diagnostics, whether or not logging
+	// was enabled when the writer was created. Otherwise they go to the
+	// standard logger if logging was enabled, and nowhere if it wasn't.
+	Logger Logger
+
+	// Host is sent as the host field of every message. It defaults to the
+	// machine's hostname, looked up once when the writer is created, or
+	// UnknownHost if the lookup fails. It can be set to something more
+	// meaningful, such as a pod or service name. An empty Host is left out.
+	Host string
+	// IncludeHost is whether the host field is sent at all. It defaults to
+	// true. Turn it off where the hostname means nothing, as on some serverless
+	// platforms, to leave LogStash to add its own. Templates are then given an
+	// empty host.
+	IncludeHost bool
+	// Version is sent as the @version field, which LogStash uses for the version
+	// of the event schema. It defaults to DefaultVersion. Set it to the empty
+	// string to leave the field out.
+	Version string
+	// TimestampFormat is the time layout used to render @timestamp
+	TimestampFormat string
+	// TimestampKey is the field the timestamp is sent in. It defaults to
+	// DefaultTimestampKey, the name Elastic uses, but other schemas expect
+	// another, such as timestamp or time. It must not be one of the other
+	// envelope fields. It has no effect on a Template.
+	TimestampKey string
+	// MessageKey is the field the message is sent in. It defaults to
+	// DefaultMessageKey, but some pipelines, with index mappings to match,
+	// expect another, such as log or msg. It must not be one of the other
+	// envelope fields. It has no effect on a Template.
+	MessageKey string
+	// Location is the time zone @timestamp is rendered in, for downstream
+	// systems which don't normalize timestamps themselves. Nil means UTC.
+	Location *time.Location
+	// DefaultFields are included in every message, such as to identify the
+	// service or environment. A field of the same name passed to LogFields
+	// takes precedence.
+	DefaultFields map[string]interface{}
+	// Sampler, if set, decides which messages logged through Log, LogFields,
+	// LogContext and the leveled helpers are sent, including those logged
+	// through a wrapper such as AsyncWriter. The rest are dropped before being
+	// encoded, and report 0 bytes written and no error. Write is not sampled.
+	Sampler Sampler
+	// ContextExtractor, if set, is called with the context passed to
+	// LogContext and LogFieldsContext, and the fields it returns are added to
+	// the message, such as a request or trace ID kept in the context. A field
+	// of the same name passed to LogFieldsContext takes precedence.
+	ContextExtractor func(ctx context.Context) map[string]interface{}
+	// Redactor, if set, is applied to every message before it is sent, such
+	// as to mask tokens or email addresses. It must be safe to call from
+	// several goroutines at once.
+	Redactor func(string) string
+	// RedactFields also applies the Redactor to every string field value,
+	// including the DefaultFields
+	RedactFields bool
+	// Terminator is appended to every message, to mark where one ends and the
+	// next begins. It defaults to DefaultTerminator, the newline that the line
+	// and json_lines codecs split on; other codecs may want something else,
+	// such as a null byte. When it is empty, nothing is appended, and messages
+	// a BatchWriter combines run together.
+	Terminator []byte
+	// Template, if set, renders each message in place of the default JSON
+	// envelope. TimestampFormat does not apply to it.
+	Template Template
+	// MaxRetries is how many times a write that fails because of a broken
+	// connection will reopen the connection and try again. Zero disables retries.
+	MaxRetries int
+	// WriteTimeout bounds how long each write to the socket may block. A write
+	// which times out is treated as a broken connection. Zero means no timeout.
+	// A deadline carried by the context passed to WriteContext takes precedence,
+	// for that write only. Either deadline is cleared once the write finishes.
+	WriteTimeout time.Duration
+	// CloseTimeout bounds how long closing the connection may take, such as
+	// for tls to send its close_notify alert to a peer which has stopped
+	// reading. Once it passes, the connection beneath is closed without
+	// waiting, and the close fails with ErrCloseTimeout. It defaults to
+	// DefaultCloseTimeout. Zero waits for as long as the connection takes.
+	CloseTimeout time.Duration
+	// Ack, if set, is the response a stream writer waits for after each write,
+	// for inputs which acknowledge what they receive. A write only succeeds once
+	// the Ack has been read, one for each message in it, so a BatchWriter's
+	// batch waits for as many as it combined. A write which isn't acknowledged
+	// in time, or is answered with anything else, fails, and the connection is
+	// replaced; the message may or may not have been received. Most LogStash
+	// inputs don't acknowledge anything, so it is empty by default. It has no
+	// effect over udp.
+	Ack []byte
+	// AckTimeout is how long a write waits for its Ack. It defaults to
+	// DefaultAckTimeout. A deadline carried by the context passed to
+	// WriteContext takes precedence, if it is sooner.
+	AckTimeout time.Duration
+	// IdleReconnect, if positive, replaces the connection once it has gone that
+	// long without a write, to keep NAT mappings from expiring and to find out
+	// an endpoint has died before a message is lost to it, rather than on the
+	// first write after a long gap. The timer is reset by every write, and
+	// takes effect from the next write or connection. OnReconnect is given
+	// ErrIdle as the cause.
+	IdleReconnect time.Duration
+	// AutoReconnect, when MaxRetries is zero, still allows a single retry on a
+	// fresh connection if a write fails because the remote end went away, such
+	// as when LogStash restarts and resets the connection
+	AutoReconnect bool
+	// MaxMessageBytes, if positive, is the longest message, in bytes, that is
+	// sent. A longer one is cut short, ending in TruncationMarker, before being
+	// encoded, so the payload is still valid JSON. Fields are not affected.
+	MaxMessageBytes int
+	// CompressAbove, if positive, gzips every udp payload larger than that many
+	// bytes, so that large messages fit in a datagram. Each compressed datagram
+	// is a whole gzip member, which begins with the gzip magic number, 1f 8b,
+	// where JSON begins with a brace, so a receiver can tell them apart.
+	// LogStash needs configuring to do so; see WithCompressAbove. It has no
+	// effect on stream writers, which can be wrapped in a GzipWriter instead.
+	CompressAbove int
+	// MaxDatagramSize is the largest payload a UDP writer will send. A larger
+	// one is refused with a *DatagramTooLargeError, rather than left for the
+	// network to fragment or drop. It defaults to DefaultMaxDatagramSize, and
+	// zero disables the check. It has no effect on stream writers.
+	MaxDatagramSize int
+	// AddSource, when true, adds a source field to every message logged, with
+	// the file and line it was logged from. Finding it walks the stack, which
+	// makes logging noticeably slower.
+	AddSource bool
+	// Sequence, when true, adds a seq field to every message logged, counting up
+	// from 1, so that a gap downstream reveals a lost message. The count belongs
+	// to the writer rather than the connection, so it carries on across Reopen.
+	// Messages logged concurrently may be sent out of sequence.
+	Sequence bool
+	seq      atomic.Uint64
+	// ReplayBufferSize is how many messages whose write failed are held on to,
+	// and sent again, oldest first, once a reconnect succeeds. It is best
+	// effort: only the most recent ReplayBufferSize failures are kept, and they
+	// are lost if the writer is closed or can't reconnect. Zero disables it.
+	ReplayBufferSize int
+	replay           [][]byte
+	// OnReconnect, if set, is called each time a new connection replaces the
+	// old one, with the error that caused it, or nil for a call to Reopen or
+	// ReopenWithBackoff. It is called while the writer is locked, so it must not
+	// use the writer.
+	OnReconnect func(err error)
+	// MinLevel is the least severe level that Debug, Info, Warn and Error will
+	// send. Messages below it are dropped before they are serialized.
+	MinLevel Level
+
+	// BackoffInitial, BackoffMax and BackoffMultiplier control the delay between
+	// dial attempts in ReopenWithBackoff. A BackoffInitial or BackoffMax which
+	// isn't positive is replaced by its default.
+	BackoffInitial    time.Duration
+	BackoffMax        time.Duration
+	BackoffMultiplier float64
+	backoffSleep      func(ctx context.Context, d time.Duration) error
+}
+
+// newBaseWriter prepares the shared state for a transport, without dialing
+func newBaseWriter(network, address string, enableLogging bool) baseWriter {
+	host, hostErr := lookupHost()
+	return baseWriter{
+		network:         network,
+		address:         address,
+		enableLogging:   enableLogging,
+		datagram:        strings.HasPrefix(network, "udp"),
+		Dialer:          net.Dial,
+		clock:           time.Now,
+		Host:            host,
+		IncludeHost:     true,
+		hostErr:         hostErr,
+		Version:         DefaultVersion,
+		TimestampFormat: DefaultTimestampFormat,
+		MessageKey:      DefaultMessageKey,
+		TimestampKey:    DefaultTimestampKey,
+		MaxDatagramSize: DefaultMaxDatagramSize,
+		CloseTimeout:    DefaultCloseTimeout,
+		Terminator:      []byte(DefaultTerminator),
+
+		BackoffInitial:    DefaultBackoffInitial,
+		BackoffMax:        DefaultBackoffMax,
+		BackoffMultiplier: DefaultBackoffMultiplier,
+		backoffSleep:      sleepContext,
+	}
+}
+
+// DefaultTimestampKey is the field the timestamp is sent in unless a writer is
+// configured otherwise
+const DefaultTimestampKey = "@timestamp"
+
+// DefaultMessageKey is the field the message is sent in unless a writer is
+// configured otherwise
+const DefaultMessageKey = "message"
+
+// DefaultVersion is the @version sent unless a writer is configured otherwise.
+// It matches the version LogStash's own codecs assign to events.
+const DefaultVersion = "1"
+
+// DefaultMaxDatagramSize is the largest payload a single UDP datagram can carry
+// over IPv4. Paths with a typical 1500 byte MTU fragment anything over about
+// 1472 bytes, so a lower MaxDatagramSize may be safer.
+const DefaultMaxDatagramSize = 65507
 
 // UDPWriter represents an abstraction over the raw UDPConn and error handling
 // for writing data to logstash via udp
 type UDPWriter struct {
-	socket        *net.UDPConn
-	address       string
-	enableLogging bool
+	baseWriter
 }
 
 // DialUDP createsa a new UDPWriter
 func DialUDP(address string, enableLogging bool) (*UDPWriter, error) {
+	return New(address, WithLogging(enableLogging))
+}
+
+// DialUDPTimeout is DialUDP, but gives up if resolving and dialing address
+// takes longer than timeout, such as when DNS is slow to answer. The same limit
+// applies whenever the connection is reopened. A dial which runs out of time
+// fails with an error matching ErrDialTimeout under errors.Is.
+func DialUDPTimeout(address string, timeout time.Duration, enableLogging bool) (*UDPWriter, error) {
+	return New(address, WithLogging(enableLogging), WithDialTimeout(timeout))
+}
+
+// DialUDPNetwork creates a new UDPWriter on a specific network, which must be
+// "udp", "udp4" or "udp6". Use "udp4" or "udp6" to force IPv4 or IPv6 when the
+// address resolves to both.
+func DialUDPNetwork(network, address string, enableLogging bool) (*UDPWriter, error) {
+	return dialUDP(network, address, []Option{WithLogging(enableLogging)})
+}
+
+// dialUDP creates a UDPWriter on network, applying opts before dialing
+func dialUDP(network, address string, opts []Option) (*UDPWriter, error) {
+	switch network {
+	case "udp", "udp4", "udp6":
+	default:
+		return nil, fmt.Errorf("logopher: unsupported udp network %q", network)
+	}
+	address, err := normalizeAddress(address)
+	if err != nil {
+		return nil, err
+	}
 	writer := &UDPWriter{
-		address:       address,
-		enableLogging: enableLogging,
+		baseWriter: newBaseWriter(network, address, false),
+	}
+	for _, opt := range opts {
+		opt(&writer.baseWriter)
 	}
 
 	if err := writer.open(); err != nil {
 		return nil, err
 	}
+	if writer.verifyOnDial {
+		if err := writer.Ping(context.Background()); err != nil {
+			writer.close()
+			return nil, err
+		}
+	}
 	return writer, nil
 }
 
 // open will dial a connection to the remote endpoint
-func (u *UDPWriter) open() error {
-	udpAddr, err := net.ResolveUDPAddr("udp", u.address)
-	if err != nil {
-		return err
+func (u *baseWriter) open() error {
+	conn, err := u.connect()
+	if err != nil && len(u.addresses) > 1 {
+		conn, err = u.dialFailover(err)
 	}
-	conn, err := net.DialUDP("udp", nil, udpAddr)
 	if err != nil {
+		u.lastErr = err
 		return err
 	}
+	if u.hostErr != nil {
+		if u.Host == UnknownHost {
+			u.logf("Failed to look up the hostname, sending %q as the host instead. Underlying error: %s", UnknownHost, u.hostErr)
+		}
+		u.hostErr = nil
+	}
+	if u.sendBufferSize > 0 {
+		if err := setWriteBuffer(conn, u.sendBufferSize); err != nil {
+			u.logf("Failed to set the send buffer size on the connection to %s. Underlying error: %s", u.address, err)
+		}
+	}
+	if u.keepAlive != nil {
+		if err := setKeepAlive(conn, *u.keepAlive); err != nil {
+			u.logf("Failed to configure keepalive on the connection to %s. Underlying error: %s", u.address, err)
+		}
+	}
+	if u.opened {
+		u.stats.reconnects.Add(1)
+	}
+	u.opened = true
 	u.socket = conn
+	u.touch()
 	return err
 }
 
-// Close will immediately call close on the connection to the remote endpoint. You
-// should not call this if other threads may be using the underlying socktet, unless
-// you control it in a mutex of some kind.
-func (u *UDPWriter) Close() error {
-	return u.socket.Close()
+// reconnect opens a new connection in place of the old one, replays any
+// failed messages on it, and reports it to OnReconnect along with the cause. It
+// fails if the replay breaks the new connection.
+func (u *baseWriter) reconnect(cause error) error {
+	if err := u.open(); err != nil {
+		return err
+	}
+	if err := u.replayFailed(); err != nil {
+		return err
+	}
+	if u.OnReconnect != nil {
+		u.OnReconnect(cause)
+	}
+	return nil
 }
 
-// Reopen allows you to close and re-establish a connection to the existing Address
-// without needing to create a whole new UDPWriter object
-func (u *UDPWriter) Reopen() error {
-	if err := u.Close(); err != nil {
-		return err
+// connect dials a new connection to the remote endpoint, without touching the
+// current one
+func (u *baseWriter) connect() (net.Conn, error) {
+	dialer := u.Dialer
+	if dialer == nil {
+		dialer = net.Dial
+	}
+	var conn net.Conn
+	var err error
+	if u.proxy != nil && strings.HasPrefix(u.network, "tcp") {
+		var timeout time.Duration
+		if u.dialer != nil {
+			timeout = u.dialer.Timeout
+		}
+		conn, err = dialHTTPProxy(dialer, u.proxy, u.address, timeout)
+	} else {
+		conn, err = dialer(u.network, u.address)
+	}
+	if err != nil {
+		return nil, err
 	}
+	if u.upgrade != nil {
+		return u.upgrade(conn)
+	}
+	return conn, nil
+}
 
-	if err := u.open(); err != nil {
+// Close will immediately call close on the connection to the remote endpoint. It
+// waits for any write already in progress to finish first. Calling it again,
+// such as from a deferred cleanup, does nothing and returns nil. Until Reopen is
+// called, writes fail with ErrClosed, and never reconnect.
+func (u *baseWriter) Close() error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.closed = true
+	u.stopIdle()
+	return u.close()
+}
+
+// close closes the socket. The caller must hold the mutex.
+func (u *baseWriter) close() error {
+	socket := u.socket
+	if socket == nil {
+		return nil
+	}
+	u.socket = nil
+	if u.CloseTimeout <= 0 {
+		return socket.Close()
+	}
+	return closeWithin(socket, u.CloseTimeout)
+}
+
+// DefaultTerminator is the Terminator of a new writer
+const DefaultTerminator = "\n"
+
+// DefaultCloseTimeout is the CloseTimeout of a new writer
+const DefaultCloseTimeout = time.Second
+
+// ErrCloseTimeout is returned when closing a connection takes longer than the
+// CloseTimeout
+var ErrCloseTimeout = errors.New("logopher: timed out closing the connection")
+
+// closeWithin closes conn, but if that takes longer than timeout, closes the
+// connection beneath it instead, which unblocks whatever conn was waiting on
+func closeWithin(conn net.Conn, timeout time.Duration) error {
+	done := make(chan error, 1)
+	go func() { done <- conn.Close() }()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case err := <-done:
 		return err
+	case <-timer.C:
 	}
 
-	return nil
+	raw := conn
+	if nc, ok := conn.(interface{ NetConn() net.Conn }); ok {
+		raw = nc.NetConn()
+	}
+	raw.Close()
+	return fmt.Errorf("%w after %s", ErrCloseTimeout, timeout)
+}
+
+// Reopen allows you to close and re-establish a connection to the existing
+// Address without needing to create a whole new writer object.
+//
+// Reopen, writes and the reconnects after a failed write all hold the writer's
+// mutex, so a Reopen called while another is in progress waits for it, then
+// replaces the connection it left, and a connection is never closed twice.
+func (u *baseWriter) Reopen() error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.closed = false
+
+	// The connection is being replaced no matter what, so a failure to close
+	// it cleanly shouldn't stop us from dialing
+	closeErr := u.close()
+	return errors.Join(closeErr, u.reconnect(nil))
+}
+
+// ErrNotConnected is the cause of a write attempted while the writer has no
+// connection, because a failed write closed it and reconnecting failed
+var ErrNotConnected = errors.New("logopher: not connected")
+
+// IsOpen reports whether the writer has a connection. It is false once a write
+// has failed and closed the connection, a Reopen has failed to dial, or Close
+// has been called, until a reconnect or Reopen succeeds.
+func (u *baseWriter) IsOpen() bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.socket != nil
+}
+
+// LastError returns the most recent error from dialing or writing, even if the
+// writer has recovered since, or nil if there has never been one
+func (u *baseWriter) LastError() error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.lastErr
+}
+
+// Conn returns the live connection, for tuning socket options this package
+// doesn't expose, such as by type asserting it to *net.UDPConn. It is for
+// advanced use only: the connection is replaced by Reopen and by reconnects
+// after a failed write, after which the one returned here is closed, and
+// writing to it directly bypasses the writer's locking. For tls, it is the
+// *tls.Conn; use its NetConn method to reach the socket beneath. It is nil
+// while the writer isn't connected.
+func (u *baseWriter) Conn() net.Conn {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.socket
 }
 
 // Log crafts a payload body, and writes it to logstash
-func (u *UDPWriter) Log(msg string) (int, error) {
-	host, _ := os.Hostname()
-	data := fmt.Sprintf(basicMessageFormat, time.Now().String(), msg, host)
-	return u.Write([]byte(data))
-	//log.Printf(data)
-	//return 0, nil
+func (u *baseWriter) Log(msg string) (int, error) {
+	return u.LogFields(msg, nil)
+}
+
+// LogFields crafts a payload body with additional structured fields alongside
+// the message, and writes it to logstash. Field values may be anything
+// encoding/json can marshal. Newlines in the message or fields, such as in a
+// stack trace, are escaped as \n inside the JSON, so the payload is always a
+// single line and the line codec delivers it as one event. Fields are never
+// allowed to overwrite the envelope keys (@timestamp, @version, message and
+// host): a field using one of those names is sent with a "fields." prefix
+// instead, e.g. "fields.host".
+func (u *baseWriter) LogFields(msg string, fields map[string]interface{}) (int, error) {
+	if u.Sampler != nil && !u.Sampler.Sample() {
+		return 0, nil
+	}
+	if u.plain(fields) {
+		return u.logPlain(u.truncate(msg))
+	}
+	data, err := u.payload(msg, fields)
+	if err != nil {
+		return 0, err
+	}
+	return u.Write(data)
+}
+
+// LogBytes is Log for a message which is already in a byte slice, such as JSON
+// produced by another encoder. The bytes become the message field as a string,
+// escaped like any other message, so LogStash receives them intact and a json
+// filter can parse them out again. To send a complete event unwrapped, use
+// LogEvent.
+func (u *baseWriter) LogBytes(b []byte) (int, error) {
+	return u.LogFields(string(b), nil)
+}
+
+// LogEvent sends event, which must be a complete JSON object, in place of the
+// envelope. Unlike Write, which sends its input byte for byte, the event is
+// validated, compacted onto a single line and followed by the Terminator, so
+// that it can't break the codec's framing. Host, DefaultFields and the rest of the
+// envelope are not added.
+func (u *baseWriter) LogEvent(event []byte) (int, error) {
+	data, err := u.eventPayload(event)
+	if err != nil {
+		return 0, err
+	}
+	return u.Write(data)
+}
+
+// LogJSON marshals v, such as a struct with json tags or a map, and sends it as
+// the whole event, as LogEvent would. v must marshal to a JSON object. An error
+// from marshalling is returned before anything is sent.
+func (u *baseWriter) LogJSON(v interface{}) (int, error) {
+	event, err := json.Marshal(v)
+	if err != nil {
+		return 0, fmt.Errorf("logopher: marshalling event: %w", err)
+	}
+	return u.LogEvent(event)
+}
+
+// eventPayload validates and compacts event for LogEvent
+func (u *baseWriter) eventPayload(event []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, event); err != nil {
+		return nil, fmt.Errorf("logopher: event is not valid JSON: %w", err)
+	}
+	if buf.Len() == 0 || buf.Bytes()[0] != '{' {
+		return nil, errors.New("logopher: event is not a JSON object")
+	}
+	buf.Write(u.Terminator)
+	return buf.Bytes(), nil
+}
+
+// encoder is implemented by writers which know how to build their own payloads.
+// Wrappers which buffer messages use it to serialize a message up front, so the
+// payload reflects the moment it was logged rather than when it was sent. A
+// message the writer would drop, such as one its Sampler rejects, fails with
+// errSampledOut.
+type encoder interface {
+	encode(msg string, fields map[string]interface{}) ([]byte, error)
+}
+
+// errSampledOut is returned by encode for a message the Sampler dropped
+var errSampledOut = errors.New("logopher: message was sampled out")
+
+// unlessSampledOut returns err, unless it only says the message was sampled
+// out, which a wrapper reports as 0 bytes written and no error, the same as
+// the writer it wraps would
+func unlessSampledOut(err error) error {
+	if err == errSampledOut {
+		return nil
+	}
+	return err
+}
+
+// encode builds the payload LogFields would send for msg and fields, for a
+// wrapper to send, or fails with errSampledOut if the Sampler drops it
+func (u *baseWriter) encode(msg string, fields map[string]interface{}) ([]byte, error) {
+	if u.Sampler != nil && !u.Sampler.Sample() {
+		return nil, errSampledOut
+	}
+	return u.payload(msg, fields)
+}
+
+// payload builds the payload LogFields would send for msg and fields, once
+// they have been sampled
+func (u *baseWriter) payload(msg string, fields map[string]interface{}) ([]byte, error) {
+	if u.plain(fields) {
+		return u.appendEnvelope(nil, u.truncate(msg)), nil
+	}
+	fields = u.withDefaultFields(fields)
+	if u.Redactor != nil {
+		msg, fields = u.redact(msg, fields)
+	}
+	// Truncated only once redacted, so a secret straddling the cut still
+	// matches the Redactor
+	msg = u.truncate(msg)
+	if _, ok := fields[SourceField]; u.AddSource && !ok {
+		// An adapter, such as the slog handler, may already know the source
+		fields = withField(fields, SourceField, callerSource())
+	}
+	if u.Sequence {
+		fields = withField(fields, SequenceField, u.seq.Add(1))
+	}
+	if u.Template != nil {
+		data, err := u.Template(msg, u.host(), u.now(), fields)
+		if err != nil {
+			return nil, err
+		}
+		return append(data, u.Terminator...), nil
+	}
+	data, err := formatMessage(u.keys(), u.timestamp(), u.Version, msg, u.host(), fields)
+	if err != nil {
+		return nil, err
+	}
+	return append(data, u.Terminator...), nil
+}
+
+// envelopeKeys are the keys of the envelope fields which can be renamed
+type envelopeKeys struct {
+	timestamp string
+	message   string
+}
+
+// defaultKeys are the envelopeKeys of a writer which hasn't renamed any
+var defaultKeys = envelopeKeys{timestamp: DefaultTimestampKey, message: DefaultMessageKey}
+
+// keys returns the envelope keys to send the timestamp and message under
+func (u *baseWriter) keys() envelopeKeys {
+	keys := envelopeKeys{timestamp: u.TimestampKey, message: u.MessageKey}
+	if keys.timestamp == "" {
+		keys.timestamp = DefaultTimestampKey
+	}
+	if keys.message == "" {
+		keys.message = DefaultMessageKey
+	}
+	return keys
+}
+
+// host returns the host to send, or the empty string if it is left out
+func (u *baseWriter) host() string {
+	if !u.IncludeHost {
+		return ""
+	}
+	return u.Host
+}
+
+// withDefaultFields merges fields over DefaultFields, and those over the
+// process fields, without modifying any of them
+func (u *baseWriter) withDefaultFields(fields map[string]interface{}) map[string]interface{} {
+	if len(u.DefaultFields) == 0 && len(u.processFields) == 0 {
+		return fields
+	}
+	merged := make(map[string]interface{}, len(u.processFields)+len(u.DefaultFields)+len(fields))
+	for k, v := range u.processFields {
+		merged[k] = v
+	}
+	for k, v := range u.DefaultFields {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return merged
+}
+
+// SequenceField is the field the sequence number is sent in when Sequence is
+// enabled
+const SequenceField = "seq"
+
+// withField copies fields, adding key set to value
+func withField(fields map[string]interface{}, key string, value interface{}) map[string]interface{} {
+	extended := make(map[string]interface{}, len(fields)+1)
+	for k, v := range fields {
+		extended[k] = v
+	}
+	extended[key] = value
+	return extended
+}
+
+// encodeFor builds the payload w would send for msg and fields, falling back to
+// the default envelope when w can't build its own
+func encodeFor(w Writer, msg string, fields map[string]interface{}) ([]byte, error) {
+	if e, ok := w.(encoder); ok {
+		return e.encode(msg, fields)
+	}
+	return marshalEvent(msg, fields)
+}
+
+// marshalEvent builds the default envelope for msg and fields, as a writer with
+// no configuration would: the current time in UTC, DefaultVersion and the
+// machine's hostname
+func marshalEvent(msg string, fields map[string]interface{}) ([]byte, error) {
+	data, err := formatMessage(defaultKeys, time.Now().UTC().Format(DefaultTimestampFormat), DefaultVersion, msg, resolveHost(), fields)
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// timestamp renders the current time using the configured TimestampFormat
+func (u *baseWriter) timestamp() string {
+	format := u.TimestampFormat
+	if format == "" {
+		format = DefaultTimestampFormat
+	}
+	return u.now().Format(format)
+}
+
+// now returns the current time in the configured Location
+func (u *baseWriter) now() time.Time {
+	if u.Location == nil {
+		return u.clock().UTC()
+	}
+	return u.clock().In(u.Location)
+}
+
+// UnknownHost is used for the host field when the hostname can't be determined
+const UnknownHost = "unknown"
+
+// osHostname looks up the hostname. It is a variable so tests can replace it.
+var osHostname = os.Hostname
+
+// resolveHost returns the machine's hostname, or UnknownHost if it can't be
+// determined
+func resolveHost() string {
+	host, _ := lookupHost()
+	return host
+}
+
+// lookupHost is resolveHost, but also reports why the lookup failed
+func lookupHost() (string, error) {
+	host, err := osHostname()
+	if err == nil && host == "" {
+		err = errors.New("logopher: empty hostname")
+	}
+	if err != nil {
+		return UnknownHost, err
+	}
+	return host, nil
+}
+
+// reservedFieldPrefix is prepended to any user supplied field whose name
+// collides with one of the envelope keys
+const reservedFieldPrefix = "fields."
+
+// formatMessage builds the JSON payload for a single message. Marshalling the
+// fields, rather than interpolating them into a string, guarantees that quotes,
+// backslashes and control characters are escaped. The payload has no
+// terminator, which is left to the caller. The timestamp and message are sent
+// under the given keys. An empty version or host leaves out the @version or
+// host field. Keys are always in sorted order, nested maps included, as
+// encoding/json sorts map keys, so the same event always encodes to the same
+// bytes. appendEnvelope's keys are in that order too.
+func formatMessage(keys envelopeKeys, timestamp, version, msg, host string, fields map[string]interface{}) ([]byte, error) {
+	event := map[string]interface{}{
+		keys.timestamp: timestamp,
+		keys.message:   msg,
+	}
+	if version != "" {
+		event["@version"] = version
+	}
+	if host != "" {
+		event["host"] = host
+	}
+	for k, v := range fields {
+		if _, reserved := event[k]; reserved {
+			k = reservedFieldPrefix + k
+		}
+		event[k] = v
+	}
+	return json.Marshal(event)
 }
 
-// Write writes the given string, plus a newline, to the LogStash server. If not
+// Write writes the given bytes to the LogStash server as-is, so they should
+// already be a formatted event. To wrap plain text in the JSON envelope, such as
+// the output of the standard log package, use Log or AsLogWriter. If not
 // all bytes can be written, Write will keep trying until the full message is
-// delivered, or the connection is broken.
-func (u *UDPWriter) Write(rawBytes []byte) (int, error) {
+// delivered, or the connection is broken. It is safe to call from multiple
+// goroutines.
+func (u *baseWriter) Write(rawBytes []byte) (int, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.write(context.Background(), rawBytes)
+}
+
+// write implements Write and WriteContext. The caller must hold the mutex.
+func (u *baseWriter) write(ctx context.Context, rawBytes []byte) (int, error) {
+	if u.closed {
+		return 0, ErrClosed
+	}
+	size := len(rawBytes)
+	if u.compresses(size) {
+		// Compressed first, since that may be what brings it under the
+		// MaxDatagramSize
+		compressed, err := gzipPayload(rawBytes)
+		if err != nil {
+			return 0, err
+		}
+		rawBytes = compressed
+	}
+	if u.datagram && u.MaxDatagramSize > 0 && len(rawBytes) > u.MaxDatagramSize {
+		u.stats.writeErrors.Add(1)
+		u.lastErr = &DatagramTooLargeError{Size: len(rawBytes), Max: u.MaxDatagramSize}
+		u.logf("Refusing to send a %d byte datagram to %s, which is over the limit of %d bytes", len(rawBytes), u.address, u.MaxDatagramSize)
+		return 0, u.lastErr
+	}
+	totalBytesWritten, writeError := u.writeAll(ctx, rawBytes)
+	for attempt := 1; writeError != nil && attempt <= u.retriesFor(writeError) && contextError(ctx) == nil; attempt++ {
+		// writeAll already closed the broken connection, so all that's left is to
+		// dial a new one and send the whole message again
+		u.logf("Retrying write to %s, attempt %d of %d", u.address, attempt, u.retriesFor(writeError))
+		if writeError = u.reconnect(writeError); writeError != nil {
+			continue
+		}
+		totalBytesWritten, writeError = u.writeAll(ctx, rawBytes)
+	}
+	if writeError != nil {
+		u.stats.writeErrors.Add(1)
+		u.keepForReplay(rawBytes)
+		if isTimeout(writeError) && contextError(ctx) == nil {
+			// A timed out write leaves part of the message on the connection, so
+			// it was closed. Dial a fresh one now, so the next write has a chance.
+			// A caller whose context ended isn't kept waiting on the dial.
+			if err := u.reconnect(writeError); err != nil {
+				u.logf("Failed to reconnect to %s after a write timed out. Underlying error: %s", u.address, err)
+			}
+		}
+		if ctxErr := contextError(ctx); ctxErr != nil {
+			// Report why the write was cut short, rather than the timeout the
+			// socket saw as a result
+			return totalBytesWritten, ctxErr
+		}
+	} else {
+		if u.awaitsAck() {
+			if ackErr := u.awaitAcks(ctx, rawBytes); ackErr != nil {
+				return totalBytesWritten, u.unacknowledged(ctx, ackErr)
+			}
+		}
+		u.touch()
+		u.stats.messagesWritten.Add(1)
+		// Report what the caller asked to write, even if less was sent once
+		// compressed
+		return size, nil
+	}
+	return totalBytesWritten, writeError
+}
+
+// retriesFor returns how many times a write which failed with err may be
+// retried
+func (u *baseWriter) retriesFor(err error) int {
+	if u.MaxRetries == 0 && u.AutoReconnect && isDisconnect(err) {
+		return 1
+	}
+	return u.MaxRetries
+}
+
+// isDisconnect reports whether err means the remote end closed or reset the
+// connection, or it was already closed, all of which a fresh connection fixes
+func isDisconnect(err error) bool {
+	for _, target := range []error{io.EOF, net.ErrClosed, ErrNotConnected, syscall.ECONNRESET, syscall.ECONNABORTED, syscall.ECONNREFUSED, syscall.EPIPE} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// maxZeroWrites is how many consecutive writes may accept no bytes, without an
+// error, before the write is abandoned
+const maxZeroWrites = 3
+
+// maxTemporaryErrors is how many temporary errors a single write may run into
+// before it is abandoned, and the connection treated as broken after all
+const maxTemporaryErrors = 3
+
+// temporaryErrorPause is how long a write waits after a temporary error before
+// trying again
+const temporaryErrorPause = 10 * time.Millisecond
+
+// isTemporary reports whether err is one which clears up by itself, such as
+// EAGAIN from a full socket buffer, rather than one which means the connection
+// is broken. Timeouts are not temporary here: they come from the deadlines
+// WriteTimeout and the context set, which trying again would defeat.
+func isTemporary(err error) bool {
+	// Checked first, since syscall.Errno counts EAGAIN as a timeout, though no
+	// deadline was involved
+	for _, target := range []error{syscall.EAGAIN, syscall.EWOULDBLOCK, syscall.ENOBUFS, syscall.EINTR} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	if isTimeout(err) || isDisconnect(err) {
+		return false
+	}
+	var temporary interface{ Temporary() bool }
+	return errors.As(err, &temporary) && temporary.Temporary()
+}
+
+// isTimeout reports whether err is a network timeout
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// writeAll makes a single attempt at delivering rawBytes over the current
+// connection, closing it if the write fails. If ctx can be canceled, its
+// deadline and cancellation are applied to the socket for the duration.
+func (u *baseWriter) writeAll(ctx context.Context, rawBytes []byte) (int, error) {
+	if u.socket == nil {
+		// Nothing to write to until a reconnect succeeds. Retries, if any, are
+		// left to write, as for any other broken connection.
+		u.lastErr = &WriteError{Expected: len(rawBytes), Cause: ErrNotConnected}
+		return 0, u.lastErr
+	}
+	_, hasDeadline := ctx.Deadline()
+	if ctx.Done() != nil {
+		defer applyContext(ctx, u.socket)()
+	}
+	useTimeout := u.WriteTimeout > 0 && !hasDeadline
+	if useTimeout {
+		defer u.socket.SetWriteDeadline(time.Time{})
+	}
+
 	toWriteLen := len(rawBytes)
 	// Three conditions could have occured:
 	// 1. There was an error
@@ -91,7 +1001,7 @@ func (u *UDPWriter) Write(rawBytes []byte) (int, error) {
 	// to try again, as we can't realistically finish the write. We have to retry it, or return
 	// and error to the user?
 
-	// TODO configurable message retries
+	// Retrying on a fresh connection is handled by write, according to MaxRetries
 
 	// If there was not an error, and we simply didn't finish the write, we should enter
 	// a write-until-complete loop, where we continue to write the data until the server accepts
@@ -103,29 +1013,62 @@ func (u *UDPWriter) Write(rawBytes []byte) (int, error) {
 	var writeError error
 	var totalBytesWritten = 0
 	var bytesWritten = 0
+	var zeroWrites = 0
+	var temporaryErrors = 0
 	for totalBytesWritten < toWriteLen && writeError == nil {
 		// While we haven't written enough yet
 		// If there are remainder bytes, adjust the slice size we go to write
 		// totalBytesWritten will be the index of the next Byte waiting to be read
+		if useTimeout {
+			u.socket.SetWriteDeadline(time.Now().Add(u.WriteTimeout))
+		}
 		bytesWritten, writeError = u.socket.Write(rawBytes[totalBytesWritten:])
 		totalBytesWritten += bytesWritten
+
+		// A datagram is sent whole or not at all, so writing the rest would only
+		// deliver it as a second, corrupt datagram
+		if u.datagram && writeError == nil && bytesWritten < toWriteLen {
+			writeError = io.ErrShortWrite
+		}
+
+		// A connection which keeps accepting nothing, without complaining, would
+		// otherwise keep us in this loop forever
+		if bytesWritten == 0 && writeError == nil {
+			zeroWrites++
+			if zeroWrites >= maxZeroWrites {
+				writeError = io.ErrShortWrite
+			}
+		} else {
+			zeroWrites = 0
+		}
+
+		// A temporary error, such as from a full socket buffer, clears up by
+		// itself, so pause and carry on from where the write got to, rather than
+		// tear down a connection which is otherwise fine
+		if writeError != nil && isTemporary(writeError) && temporaryErrors < maxTemporaryErrors && contextError(ctx) == nil {
+			temporaryErrors++
+			u.logf("Temporary error writing to %s, trying again. Underlying error: %s", u.address, writeError)
+			time.Sleep(temporaryErrorPause)
+			writeError = nil
+		}
 	}
+	u.stats.bytesWritten.Add(uint64(totalBytesWritten))
 
+	if writeError == nil {
+		u.failures = 0
+	}
 	if writeError != nil {
-		if u.enableLogging {
-			log.Printf("Error while writing data to %s. Expected to write %d, actually wrote %d. Underlying error: %s", u.address, toWriteLen, totalBytesWritten, writeError)
-		}
-		writeError = u.Close()
-		if writeError != nil {
-			// TODO ponder the following:
-			// What if some bytes written, then failure, then also the close throws an error
-			// []error is a better return type, but not sure if thats a thing you're supposed to do...
-			// Possibilities for error not as complicated as i'm thinking?
-			if u.enableLogging {
-				// The error will get returned up the stack, no need to log it here?
-				log.Printf("There was a subsequent error cleaning up the connection to %s", u.address)
-			}
-			return totalBytesWritten, writeError
+		// Counted once everything below has been reported against the address
+		// the write failed on
+		defer u.writeFailed()
+		u.logf("Error while writing data to %s. Expected to write %d, actually wrote %d. Underlying error: %s", u.address, toWriteLen, totalBytesWritten, writeError)
+		writeError = &WriteError{Written: totalBytesWritten, Expected: toWriteLen, Cause: writeError}
+		u.lastErr = writeError
+		if closeError := u.close(); closeError != nil {
+			// Both failures are returned, so that neither how much was written nor
+			// why the connection couldn't be cleaned up is lost
+			u.logf("There was a subsequent error cleaning up the connection to %s. Underlying error: %s", u.address, closeError)
+			return totalBytesWritten, errors.Join(writeError, &CloseError{Cause: closeError})
 		}
 	}
 
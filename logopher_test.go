@@ -1,18 +1,1301 @@
 package logopher
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log"
+	"net"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"syscall"
 	"testing"
+	"time"
 )
 
+// listenUDP opens a local UDP socket to stand in for LogStash
+func listenUDP(t *testing.T) *net.UDPConn {
+	addr, err := net.ResolveUDPAddr("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// readDatagram reads a single datagram from the listener, failing the test if
+// nothing arrives in a reasonable amount of time
+func readDatagram(t *testing.T, conn *net.UDPConn) []byte {
+	buf := make([]byte, 65536)
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, _, err := conn.ReadFromUDP(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf[:n]
+}
+
 func TestLogopher(t *testing.T) {
-	w, err := DialUDP("ADDRESS", true)
+	l := listenUDP(t)
+	w, err := DialUDP(l.LocalAddr().String(), true)
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
+	defer w.Close()
 	num, err := w.Log("Hello Smithers, you're quite good at turning me on")
 	log.Printf("Wrote: %d", num)
 	if err != nil {
 		t.Error(err)
 	}
+	if data := readDatagram(t, l); len(data) != num {
+		t.Errorf("Expected to receive %d bytes, got %d", num, len(data))
+	}
+}
+
+func TestLogEscapesMessage(t *testing.T) {
+	l := listenUDP(t)
+	w, err := DialUDP(l.LocalAddr().String(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	messages := []string{
+		`he said "hi"`,
+		`C:\Windows\System32`,
+		"tab\tseparated",
+		"line one\nline two\r\n",
+		"bell \x07 and null \x00",
+		"unicode: héllo, 世界, 🐹",
+		"</script><script>alert(1)</script>",
+	}
+	for _, msg := range messages {
+		if _, err := w.Log(msg); err != nil {
+			t.Fatal(err)
+		}
+		data := readDatagram(t, l)
+		if data[len(data)-1] != '\n' {
+			t.Errorf("Expected payload for %q to end in a newline", msg)
+		}
+		var event map[string]string
+		if err := json.Unmarshal(data, &event); err != nil {
+			t.Errorf("Payload for %q is not valid JSON: %s", msg, err)
+			continue
+		}
+		if event["message"] != msg {
+			t.Errorf("Expected message %q, got %q", msg, event["message"])
+		}
+	}
+}
+
+func TestLogTimestampIsRFC3339(t *testing.T) {
+	l := listenUDP(t)
+	w, err := DialUDP(l.LocalAddr().String(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	if _, err := w.Log("what time is it"); err != nil {
+		t.Fatal(err)
+	}
+	var event map[string]string
+	if err := json.Unmarshal(readDatagram(t, l), &event); err != nil {
+		t.Fatal(err)
+	}
+	ts, err := time.Parse(time.RFC3339Nano, event["@timestamp"])
+	if err != nil {
+		t.Fatalf("Timestamp %q did not parse: %s", event["@timestamp"], err)
+	}
+	if ts.Location() != time.UTC {
+		t.Errorf("Expected a UTC timestamp, got %s", ts.Location())
+	}
+}
+
+func TestLogCustomTimestampFormat(t *testing.T) {
+	l := listenUDP(t)
+	w, err := DialUDP(l.LocalAddr().String(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	w.TimestampFormat = "2006-01-02T15:04:05.000Z07:00"
+	if _, err := w.Log("millis please"); err != nil {
+		t.Fatal(err)
+	}
+	var event map[string]string
+	if err := json.Unmarshal(readDatagram(t, l), &event); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := time.Parse(w.TimestampFormat, event["@timestamp"]); err != nil {
+		t.Errorf("Timestamp %q did not match the custom format: %s", event["@timestamp"], err)
+	}
+}
+
+func TestLogLocation(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("No time zone database: %s", err)
+	}
+	tests := []struct {
+		location *time.Location
+	}{
+		{time.UTC},
+		{newYork},
+	}
+	for _, test := range tests {
+		conn := &fakeConn{}
+		w, _ := newFakeWriter(t, conn)
+		w.Location = test.location
+
+		before := time.Now()
+		w.Log("zoned")
+		ts, err := time.Parse(time.RFC3339Nano, lastEvent(t, conn)["@timestamp"].(string))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, wantOffset := before.In(test.location).Zone()
+		if _, offset := ts.Zone(); offset != wantOffset {
+			t.Errorf("Expected a %s offset of %d, got %d", test.location, wantOffset, offset)
+		}
+	}
+}
+
+func TestConcurrentLog(t *testing.T) {
+	l, lines := listenTCP(t)
+	w, err := DialTCP(l.Addr().String(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	const count = 500
+	var wg sync.WaitGroup
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, err := w.Log(fmt.Sprintf("message %d %s", i, strings.Repeat("padding", 100))); err != nil {
+				t.Error(err)
+			}
+			if i%50 == 0 {
+				if err := w.Reopen(); err != nil {
+					t.Error(err)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	seen := make(map[string]bool)
+	for i := 0; i < count; i++ {
+		var event map[string]string
+		line := readLine(t, lines)
+		if err := json.Unmarshal(line, &event); err != nil {
+			t.Fatalf("Received a corrupted message %q: %s", line, err)
+		}
+		seen[event["message"]] = true
+	}
+	if len(seen) != count {
+		t.Errorf("Expected %d distinct messages, got %d", count, len(seen))
+	}
+}
+
+// fakeAddr satisfies net.Addr for fakeConn
+type fakeAddr string
+
+func (a fakeAddr) Network() string { return "fake" }
+func (a fakeAddr) String() string  { return string(a) }
+
+// fakeConn is an in-memory net.Conn which records everything written to it, and
+// can be told to fail writes
+type fakeConn struct {
+	mu         sync.Mutex
+	written    [][]byte
+	failWrites int
+	writeErr   error
+	zeroWrites bool
+	maxWrite   int
+	closed     bool
+	closeErr   error
+	deadlines  []time.Time
+}
+
+func (c *fakeConn) Write(b []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return 0, net.ErrClosed
+	}
+	if c.failWrites > 0 {
+		c.failWrites--
+		return 0, c.writeErr
+	}
+	if c.zeroWrites {
+		return 0, nil
+	}
+	if c.maxWrite > 0 && len(b) > c.maxWrite {
+		b = b[:c.maxWrite]
+	}
+	c.written = append(c.written, append([]byte(nil), b...))
+	return len(b), nil
+}
+
+func (c *fakeConn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	return c.closeErr
+}
+
+func (c *fakeConn) Writes() [][]byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([][]byte(nil), c.written...)
+}
+
+func (c *fakeConn) Read(b []byte) (int, error)        { return 0, io.EOF }
+func (c *fakeConn) LocalAddr() net.Addr               { return fakeAddr("local") }
+func (c *fakeConn) RemoteAddr() net.Addr              { return fakeAddr("remote") }
+func (c *fakeConn) SetDeadline(t time.Time) error     { return nil }
+func (c *fakeConn) SetReadDeadline(t time.Time) error { return nil }
+
+func (c *fakeConn) SetWriteDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.deadlines = append(c.deadlines, t)
+	return nil
+}
+
+// Deadlines returns every write deadline set on the conn, in order
+func (c *fakeConn) Deadlines() []time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]time.Time(nil), c.deadlines...)
+}
+
+// fakeDialer hands out the given conns in order, one per dial
+type fakeDialer struct {
+	mu    sync.Mutex
+	conns []*fakeConn
+	dials int
+}
+
+func (d *fakeDialer) dial(network, address string) (net.Conn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.dials >= len(d.conns) {
+		return nil, errors.New("fakeDialer: no more conns")
+	}
+	conn := d.conns[d.dials]
+	d.dials++
+	return conn, nil
+}
+
+// newFakeWriter builds a writer whose connections come from the given conns. It
+// behaves as a stream transport, like tcp.
+func newFakeWriter(t *testing.T, conns ...*fakeConn) (*UDPWriter, *fakeDialer) {
+	d := &fakeDialer{conns: conns}
+	w := &UDPWriter{baseWriter: newBaseWriter("tcp", "fake:5000", false)}
+	w.Dialer = d.dial
+	if err := w.open(); err != nil {
+		t.Fatal(err)
+	}
+	return w, d
+}
+
+func TestWriteRetriesOnBrokenConnection(t *testing.T) {
+	broken := &fakeConn{failWrites: 1, writeErr: errors.New("connection refused")}
+	alsoBroken := &fakeConn{failWrites: 1, writeErr: errors.New("connection refused")}
+	healthy := &fakeConn{}
+	w, d := newFakeWriter(t, broken, alsoBroken, healthy)
+	w.MaxRetries = 2
+
+	n, err := w.Write([]byte("hello\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 6 {
+		t.Errorf("Expected to write 6 bytes, wrote %d", n)
+	}
+	if d.dials != 3 {
+		t.Errorf("Expected 3 dials, got %d", d.dials)
+	}
+	if !broken.closed || !alsoBroken.closed {
+		t.Error("Expected the broken connections to be closed")
+	}
+	if writes := healthy.Writes(); len(writes) != 1 || string(writes[0]) != "hello\n" {
+		t.Errorf("Expected the message to arrive on the healthy connection, got %q", writes)
+	}
+}
+
+func TestWriteGivesUpAfterMaxRetries(t *testing.T) {
+	writeErr := errors.New("connection refused")
+	conns := []*fakeConn{
+		{failWrites: 1, writeErr: writeErr},
+		{failWrites: 1, writeErr: writeErr},
+		{},
+	}
+	w, d := newFakeWriter(t, conns...)
+	w.MaxRetries = 1
+
+	if _, err := w.Write([]byte("hello\n")); !errors.Is(err, writeErr) {
+		t.Errorf("Expected %v, got %v", writeErr, err)
+	}
+	if d.dials != 2 {
+		t.Errorf("Expected 2 dials, got %d", d.dials)
+	}
+}
+
+func TestWriteWithoutRetries(t *testing.T) {
+	writeErr := errors.New("connection refused")
+	w, d := newFakeWriter(t, &fakeConn{failWrites: 1, writeErr: writeErr}, &fakeConn{})
+
+	if _, err := w.Write([]byte("hello\n")); !errors.Is(err, writeErr) {
+		t.Errorf("Expected %v, got %v", writeErr, err)
+	}
+	if d.dials != 1 {
+		t.Errorf("Expected no redial, got %d dials", d.dials)
+	}
+}
+
+func TestReopenDialsWhenCloseFails(t *testing.T) {
+	closeErr := errors.New("bad file descriptor")
+	w, d := newFakeWriter(t, &fakeConn{closeErr: closeErr}, &fakeConn{})
+
+	if err := w.Reopen(); !errors.Is(err, closeErr) {
+		t.Errorf("Expected %v, got %v", closeErr, err)
+	}
+	if d.dials != 2 {
+		t.Errorf("Expected 2 dials, got %d", d.dials)
+	}
+	if !w.IsOpen() {
+		t.Error("Expected the writer to be connected after Reopen")
+	}
+}
+
+func TestLogFields(t *testing.T) {
+	conn := &fakeConn{}
+	w, _ := newFakeWriter(t, conn)
+
+	fields := map[string]interface{}{
+		"level":      "warn",
+		"request_id": 42,
+		"retried":    true,
+		"http":       map[string]interface{}{"status": 503, "path": "/health"},
+		"message":    "sneaky",
+		"@timestamp": "yesterday",
+	}
+	if _, err := w.LogFields("upstream unavailable", fields); err != nil {
+		t.Fatal(err)
+	}
+
+	var event map[string]interface{}
+	if err := json.Unmarshal(conn.Writes()[0], &event); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"message":           "upstream unavailable",
+		"level":             "warn",
+		"request_id":        float64(42),
+		"retried":           true,
+		"http":              map[string]interface{}{"status": float64(503), "path": "/health"},
+		"fields.message":    "sneaky",
+		"fields.@timestamp": "yesterday",
+		"@version":          "1",
+	}
+	for k, v := range expected {
+		if !reflect.DeepEqual(event[k], v) {
+			t.Errorf("Expected %s to be %#v, got %#v", k, v, event[k])
+		}
+	}
+	if event["@timestamp"] == "yesterday" {
+		t.Error("A field was allowed to overwrite @timestamp")
+	}
+}
+
+func TestLogFieldsUnmarshalable(t *testing.T) {
+	conn := &fakeConn{}
+	w, _ := newFakeWriter(t, conn)
+
+	if _, err := w.LogFields("nope", map[string]interface{}{"ch": make(chan int)}); err == nil {
+		t.Error("Expected an error for a field that can't be marshalled")
+	}
+	if len(conn.Writes()) != 0 {
+		t.Error("Expected nothing to be written")
+	}
+}
+
+// recordingWriter is a FieldLogger which records every payload written to it.
+// When gate is set, each write waits to receive from it first.
+type recordingWriter struct {
+	mu       sync.Mutex
+	payloads [][]byte
+	gate     chan struct{}
+	writeErr error
+	closed   bool
+	reopens  int
+}
+
+func (r *recordingWriter) Log(msg string) (int, error) {
+	return r.LogFields(msg, nil)
+}
+
+func (r *recordingWriter) LogFields(msg string, fields map[string]interface{}) (int, error) {
+	data, err := encodeFor(r, msg, fields)
+	if err != nil {
+		return 0, err
+	}
+	return r.Write(data)
+}
+
+func (r *recordingWriter) Write(b []byte) (int, error) {
+	if r.gate != nil {
+		<-r.gate
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.writeErr != nil {
+		return 0, r.writeErr
+	}
+	r.payloads = append(r.payloads, append([]byte(nil), b...))
+	return len(b), nil
+}
+
+func (r *recordingWriter) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+	return nil
+}
+
+func (r *recordingWriter) Reopen() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.reopens++
+	return nil
+}
+
+func (r *recordingWriter) Payloads() [][]byte {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([][]byte(nil), r.payloads...)
+}
+
+// Messages parses each recorded payload and returns its message field
+func (r *recordingWriter) Messages(t *testing.T) []string {
+	var messages []string
+	for _, p := range r.Payloads() {
+		var event map[string]interface{}
+		if err := json.Unmarshal(p, &event); err != nil {
+			t.Fatal(err)
+		}
+		msg, _ := event["message"].(string)
+		messages = append(messages, msg)
+	}
+	return messages
+}
+
+func TestHostResolvedOnce(t *testing.T) {
+	lookups := 0
+	osHostname = func() (string, error) {
+		lookups++
+		return "box-1", nil
+	}
+	defer func() { osHostname = os.Hostname }()
+
+	conn := &fakeConn{}
+	w, _ := newFakeWriter(t, conn)
+	for i := 0; i < 3; i++ {
+		if _, err := w.Log("hi"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if lookups != 1 {
+		t.Errorf("Expected the hostname to be looked up once, got %d", lookups)
+	}
+	if event := lastEvent(t, conn); event["host"] != "box-1" {
+		t.Errorf("Expected host box-1, got %v", event["host"])
+	}
+}
+
+func TestHostOverride(t *testing.T) {
+	conn := &fakeConn{}
+	w, _ := newFakeWriter(t, conn)
+	w.Host = "auth-7f9c"
+
+	if _, err := w.Log("hi"); err != nil {
+		t.Fatal(err)
+	}
+	if event := lastEvent(t, conn); event["host"] != "auth-7f9c" {
+		t.Errorf("Expected host auth-7f9c, got %v", event["host"])
+	}
+}
+
+func TestHostFallsBackToUnknown(t *testing.T) {
+	osHostname = func() (string, error) { return "", errors.New("no hostname") }
+	defer func() { osHostname = os.Hostname }()
+
+	w, _ := newFakeWriter(t, &fakeConn{})
+	if w.Host != UnknownHost {
+		t.Errorf("Expected host %q, got %q", UnknownHost, w.Host)
+	}
+}
+
+func TestHostLookupFailureIsLogged(t *testing.T) {
+	osHostname = func() (string, error) { return "", errors.New("no hostname") }
+	defer func() { osHostname = os.Hostname }()
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	defer log.SetOutput(os.Stderr)
+
+	conn := &fakeConn{}
+	d := &fakeDialer{conns: []*fakeConn{conn}}
+	w, err := New("fake:5000", WithLogging(true), WithDialer(d.dial))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Log("hi")
+	if event := lastEvent(t, conn); event["host"] != UnknownHost {
+		t.Errorf("Expected host %q, got %v", UnknownHost, event["host"])
+	}
+	if !strings.Contains(logged.String(), "no hostname") {
+		t.Errorf("Expected the lookup failure to be logged, got %q", logged.String())
+	}
+
+	// A host set explicitly makes the failure irrelevant
+	logged.Reset()
+	d = &fakeDialer{conns: []*fakeConn{{}}}
+	if _, err := New("fake:5000", WithLogging(true), WithDialer(d.dial), WithHost("pod-7")); err != nil {
+		t.Fatal(err)
+	}
+	if logged.Len() != 0 {
+		t.Errorf("Expected nothing logged, got %q", logged.String())
+	}
+}
+
+func TestDialerInjection(t *testing.T) {
+	l := listenUDP(t)
+	w, err := DialUDP(l.LocalAddr().String(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	conn := &fakeConn{}
+	var network, address string
+	w.Dialer = func(n, a string) (net.Conn, error) {
+		network, address = n, a
+		return conn, nil
+	}
+	if err := w.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	if network != "udp" || address != l.LocalAddr().String() {
+		t.Errorf("Dialer called with (%q, %q)", network, address)
+	}
+
+	w.Log("through the fake")
+	if writes := conn.Writes(); len(writes) != 1 {
+		t.Errorf("Expected the fake conn to record 1 write, got %d", len(writes))
+	}
+}
+
+func TestAutoReconnect(t *testing.T) {
+	reset := &fakeConn{failWrites: 1, writeErr: syscall.ECONNRESET}
+	healthy := &fakeConn{}
+	w, d := newFakeWriter(t, reset, healthy)
+	w.AutoReconnect = true
+
+	if _, err := w.Write([]byte("after restart\n")); err != nil {
+		t.Fatal(err)
+	}
+	if d.dials != 2 {
+		t.Errorf("Expected a reconnect, got %d dials", d.dials)
+	}
+	if writes := healthy.Writes(); len(writes) != 1 {
+		t.Errorf("Expected the message on the new connection, got %q", writes)
+	}
+}
+
+func TestAutoReconnectIgnoresOtherErrors(t *testing.T) {
+	writeErr := errors.New("message too long")
+	w, d := newFakeWriter(t, &fakeConn{failWrites: 1, writeErr: writeErr}, &fakeConn{})
+	w.AutoReconnect = true
+
+	if _, err := w.Write([]byte("hello\n")); !errors.Is(err, writeErr) {
+		t.Errorf("Expected %v, got %v", writeErr, err)
+	}
+	if d.dials != 1 {
+		t.Errorf("Expected no reconnect, got %d dials", d.dials)
+	}
+}
+
+func TestAutoReconnectRetriesOnce(t *testing.T) {
+	conns := []*fakeConn{
+		{failWrites: 1, writeErr: io.EOF},
+		{failWrites: 1, writeErr: io.EOF},
+		{},
+	}
+	w, d := newFakeWriter(t, conns...)
+	w.AutoReconnect = true
+
+	if _, err := w.Write([]byte("hello\n")); !errors.Is(err, io.EOF) {
+		t.Errorf("Expected io.EOF, got %v", err)
+	}
+	if d.dials != 2 {
+		t.Errorf("Expected a single reconnect, got %d dials", d.dials)
+	}
+}
+
+func TestDefaultFields(t *testing.T) {
+	conn := &fakeConn{}
+	w, _ := newFakeWriter(t, conn)
+	w.DefaultFields = map[string]interface{}{"service": "auth", "env": "prod"}
+
+	w.Log("plain")
+	event := lastEvent(t, conn)
+	if event["service"] != "auth" || event["env"] != "prod" {
+		t.Errorf("Expected the default fields, got %v", event)
+	}
+
+	w.LogFields("override", map[string]interface{}{"env": "staging", "user": "bob"})
+	event = lastEvent(t, conn)
+	if event["service"] != "auth" || event["env"] != "staging" || event["user"] != "bob" {
+		t.Errorf("Expected the per-call field to win, got %v", event)
+	}
+	if w.DefaultFields["env"] != "prod" {
+		t.Error("Expected DefaultFields to be left untouched")
+	}
+
+	w.Error("leveled")
+	if event := lastEvent(t, conn); event["service"] != "auth" || event["level"] != "error" {
+		t.Errorf("Expected the default fields on leveled messages, got %v", event)
+	}
+}
+
+func TestDialUDPNetworkIPv6(t *testing.T) {
+	addr, err := net.ResolveUDPAddr("udp6", "[::1]:0")
+	if err != nil {
+		t.Skip("IPv6 loopback unavailable:", err)
+	}
+	l, err := net.ListenUDP("udp6", addr)
+	if err != nil {
+		t.Skip("IPv6 loopback unavailable:", err)
+	}
+	defer l.Close()
+
+	w, err := DialUDPNetwork("udp6", l.LocalAddr().String(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if network := w.socket.RemoteAddr().Network(); network != "udp" {
+		t.Errorf("Expected a udp socket, got %s", network)
+	}
+	if ip := w.socket.RemoteAddr().(*net.UDPAddr).IP; ip.To4() != nil {
+		t.Errorf("Expected an IPv6 remote address, got %s", ip)
+	}
+	if _, err := w.Log("over ipv6"); err != nil {
+		t.Fatal(err)
+	}
+	readDatagram(t, l)
+
+	if _, err := DialUDPNetwork("udp4", l.LocalAddr().String(), false); err == nil {
+		t.Error("Expected udp4 to refuse an IPv6 address")
+	}
+}
+
+func TestDialUDPNetworkUnsupported(t *testing.T) {
+	if _, err := DialUDPNetwork("tcp", "127.0.0.1:5000", false); err == nil {
+		t.Error("Expected a non-udp network to be rejected")
+	}
+}
+
+func TestWriteStopsSpinningOnZeroByteWrites(t *testing.T) {
+	conn := &fakeConn{zeroWrites: true}
+	w, _ := newFakeWriter(t, conn)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := w.Write([]byte("going nowhere\n"))
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if !errors.Is(err, io.ErrShortWrite) {
+			t.Errorf("Expected io.ErrShortWrite, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Write never returned")
+	}
+	if !conn.closed {
+		t.Error("Expected the connection to be closed")
+	}
+}
+
+func TestVersion(t *testing.T) {
+	conn := &fakeConn{}
+	w, _ := newFakeWriter(t, conn)
+
+	w.Log("default")
+	if event := lastEvent(t, conn); event["@version"] != DefaultVersion {
+		t.Errorf("Expected @version %q, got %v", DefaultVersion, event["@version"])
+	}
+
+	w.Version = "2"
+	w.Log("configured")
+	if event := lastEvent(t, conn); event["@version"] != "2" {
+		t.Errorf("Expected @version 2, got %v", event["@version"])
+	}
+
+	w.Version = ""
+	w.Log("omitted")
+	if _, ok := lastEvent(t, conn)["@version"]; ok {
+		t.Error("Expected @version to be left out")
+	}
+}
+
+func TestLogBytes(t *testing.T) {
+	conn := &fakeConn{}
+	w, _ := newFakeWriter(t, conn)
+
+	inputs := []string{`{"user":"bob","action":"login"}`, `not "json" at all\`}
+	for _, input := range inputs {
+		if _, err := w.LogBytes([]byte(input)); err != nil {
+			t.Fatal(err)
+		}
+		if msg := lastEvent(t, conn)["message"]; msg != input {
+			t.Errorf("Expected message %q, got %v", input, msg)
+		}
+	}
+}
+
+func TestLogEvent(t *testing.T) {
+	conn := &fakeConn{}
+	w, _ := newFakeWriter(t, conn)
+
+	if _, err := w.LogEvent([]byte("{\n  \"message\": \"pre-built\",\n  \"level\": \"info\"\n}")); err != nil {
+		t.Fatal(err)
+	}
+	writes := conn.Writes()
+	if got := string(writes[len(writes)-1]); got != `{"message":"pre-built","level":"info"}`+"\n" {
+		t.Errorf("Expected the event compacted onto one line, got %q", got)
+	}
+
+	for _, invalid := range []string{`{"message":`, `["not", "an", "object"]`, ``} {
+		if _, err := w.LogEvent([]byte(invalid)); err == nil {
+			t.Errorf("Expected %q to be rejected", invalid)
+		}
+	}
+	if got := len(conn.Writes()); got != len(writes) {
+		t.Errorf("Expected nothing written for invalid events, got %d more writes", got-len(writes))
+	}
+}
+
+func TestLogJSON(t *testing.T) {
+	conn := &fakeConn{}
+	w, _ := newFakeWriter(t, conn)
+
+	type order struct {
+		Message string   `json:"message"`
+		ID      int      `json:"order_id"`
+		Items   []string `json:"items,omitempty"`
+		secret  string
+	}
+	if _, err := w.LogJSON(order{Message: "order placed", ID: 42, secret: "hidden"}); err != nil {
+		t.Fatal(err)
+	}
+	writes := conn.Writes()
+	if got := string(writes[len(writes)-1]); got != `{"message":"order placed","order_id":42}`+"\n" {
+		t.Errorf("Expected the struct marshalled by its tags, got %q", got)
+	}
+
+	if _, err := w.LogJSON(map[string]interface{}{"bad": make(chan int)}); err == nil {
+		t.Error("Expected an error for a value which can't be marshalled")
+	}
+	if _, err := w.LogJSON([]int{1, 2}); err == nil {
+		t.Error("Expected an error for a value which isn't an object")
+	}
+	if got := len(conn.Writes()); got != len(writes) {
+		t.Errorf("Expected nothing written after errors, got %d more writes", got-len(writes))
+	}
+}
+
+func TestOnReconnect(t *testing.T) {
+	writeErr := errors.New("connection reset")
+	w, _ := newFakeWriter(t, &fakeConn{failWrites: 1, writeErr: writeErr}, &fakeConn{}, &fakeConn{})
+	w.MaxRetries = 1
+	var causes []error
+	w.OnReconnect = func(err error) { causes = append(causes, err) }
+
+	if _, err := w.Write([]byte("hello\n")); err != nil {
+		t.Fatal(err)
+	}
+	if len(causes) != 1 || !errors.Is(causes[0], writeErr) {
+		t.Fatalf("Expected one reconnect caused by %v, got %v", writeErr, causes)
+	}
+
+	if err := w.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	if len(causes) != 2 || causes[1] != nil {
+		t.Errorf("Expected a manual Reopen to report a nil cause, got %v", causes)
+	}
+}
+
+func TestSequence(t *testing.T) {
+	first, second := &fakeConn{}, &fakeConn{}
+	w, _ := newFakeWriter(t, first, second)
+	w.Sequence = true
+	w.DefaultFields = map[string]interface{}{"service": "billing"}
+
+	for i := 0; i < 3; i++ {
+		w.Log("counted")
+	}
+	if err := w.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	w.LogFields("counted", map[string]interface{}{"user": "bob"})
+
+	var got []float64
+	for _, conn := range []*fakeConn{first, second} {
+		for _, p := range conn.Writes() {
+			var event map[string]interface{}
+			if err := json.Unmarshal(p, &event); err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, event[SequenceField].(float64))
+		}
+	}
+	if expected := []float64{1, 2, 3, 4}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected sequence %v, got %v", expected, got)
+	}
+	if len(w.DefaultFields) != 1 {
+		t.Error("Expected DefaultFields to be left untouched")
+	}
+}
+
+func TestUDPShortWriteIsNotContinued(t *testing.T) {
+	conns := []*fakeConn{{maxWrite: 4}, {}}
+	d := &fakeDialer{conns: conns}
+	w := &UDPWriter{baseWriter: newBaseWriter("udp", "fake:5000", false)}
+	w.Dialer = d.dial
+	if err := w.open(); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := w.Write([]byte("hello world\n"))
+	if !errors.Is(err, io.ErrShortWrite) {
+		t.Errorf("Expected io.ErrShortWrite, got %v", err)
+	}
+	if writes := conns[0].Writes(); len(writes) != 1 {
+		t.Errorf("Expected a single truncated datagram and no tail, got %q", writes)
+	}
+
+	// With a retry, the whole datagram is sent again on a fresh connection
+	w.MaxRetries = 1
+	if _, err := w.Write([]byte("hello world\n")); err != nil {
+		t.Fatal(err)
+	}
+	if writes := conns[1].Writes(); len(writes) != 1 || string(writes[0]) != "hello world\n" {
+		t.Errorf("Expected the datagram resent whole, got %q", writes)
+	}
+}
+
+func TestConn(t *testing.T) {
+	l := listenUDP(t)
+	w, err := DialUDP(l.LocalAddr().String(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	conn, ok := w.Conn().(*net.UDPConn)
+	if !ok {
+		t.Fatalf("Expected a *net.UDPConn, got %T", w.Conn())
+	}
+	if err := conn.SetWriteBuffer(1 << 16); err != nil {
+		t.Error(err)
+	}
+	// Writing to it directly proves it's the socket the writer uses
+	if _, err := conn.Write([]byte("direct\n")); err != nil {
+		t.Fatal(err)
+	}
+	if got := string(readDatagram(t, l)); got != "direct\n" {
+		t.Errorf("Expected the datagram written directly, got %q", got)
+	}
+
+	if err := w.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	if w.Conn() == net.Conn(conn) {
+		t.Error("Expected Reopen to replace the connection")
+	}
+}
+
+func TestMarshalEvent(t *testing.T) {
+	fields := map[string]interface{}{
+		"count":   42,
+		"ratio":   0.25,
+		"ok":      true,
+		"missing": nil,
+		"tags":    []string{"a", `"quoted"`},
+		"request": map[string]interface{}{
+			"path":    "/search?q=<script>",
+			"headers": map[string]interface{}{"Accept": "*/*"},
+			"sizes":   []int{1, 2, 3},
+		},
+		"note": "tab\there\nnewline \\ backslash",
+	}
+	data, err := marshalEvent("structured", fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Count(data, []byte("\n")) != 1 || data[len(data)-1] != '\n' {
+		t.Errorf("Expected a single newline terminated line, got %q", data)
+	}
+
+	var event map[string]interface{}
+	if err := json.Unmarshal(data, &event); err != nil {
+		t.Fatal(err)
+	}
+	if event["message"] != "structured" || event["@version"] != DefaultVersion || event["host"] != resolveHost() {
+		t.Errorf("Expected the default envelope, got %v", event)
+	}
+	if _, err := time.Parse(DefaultTimestampFormat, event["@timestamp"].(string)); err != nil {
+		t.Error(err)
+	}
+	// Round trip the fields through JSON, so numbers compare as float64
+	var expected map[string]interface{}
+	raw, _ := json.Marshal(fields)
+	json.Unmarshal(raw, &expected)
+	for k, v := range expected {
+		if !reflect.DeepEqual(event[k], v) {
+			t.Errorf("Expected %s to be %v, got %v", k, v, event[k])
+		}
+	}
+
+	if _, err := marshalEvent("unmarshalable", map[string]interface{}{"ch": make(chan int)}); err == nil {
+		t.Error("Expected a value encoding/json can't marshal to fail")
+	}
+}
+
+func TestTerminator(t *testing.T) {
+	tests := []struct {
+		name       string
+		terminator []byte
+	}{
+		{"default", []byte(DefaultTerminator)},
+		{"null byte", []byte{0}},
+		{"empty", nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn := &fakeConn{}
+			w, _ := newFakeWriter(t, conn)
+			w.Terminator = test.terminator
+
+			w.Log("plain")
+			w.LogFields("with fields", map[string]interface{}{"k": "v"})
+			w.LogEvent([]byte(`{"a": 1}`))
+			for i, payload := range conn.Writes() {
+				body, found := bytes.CutSuffix(payload, test.terminator)
+				if !found {
+					t.Errorf("Write %d: Expected the payload to end with %q, got %q", i, test.terminator, payload)
+				}
+				if body[len(body)-1] != '}' {
+					t.Errorf("Write %d: Expected nothing after the JSON but the terminator, got %q", i, payload)
+				}
+			}
+		})
+	}
+}
+
+func TestIsOpenAndLastError(t *testing.T) {
+	refused := errors.New("connection refused")
+	broken := &fakeConn{failWrites: 1, writeErr: refused}
+	healthy := &fakeConn{}
+	w, _ := newFakeWriter(t, broken, healthy)
+
+	if !w.IsOpen() {
+		t.Error("Expected a new writer to be open")
+	}
+	if err := w.LastError(); err != nil {
+		t.Errorf("Expected no error yet, got %v", err)
+	}
+
+	if _, err := w.Write([]byte("lost\n")); err == nil {
+		t.Fatal("Expected the write to fail")
+	}
+	if w.IsOpen() {
+		t.Error("Expected the failed write to leave the writer closed")
+	}
+	if err := w.LastError(); !errors.Is(err, refused) {
+		t.Errorf("Expected LastError to be the write's failure, got %v", err)
+	}
+
+	if err := w.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	if !w.IsOpen() {
+		t.Error("Expected Reopen to leave the writer open")
+	}
+	if err := w.LastError(); !errors.Is(err, refused) {
+		t.Errorf("Expected LastError to still report the earlier failure, got %v", err)
+	}
+
+	// There are no more conns, so this reopen fails
+	if err := w.Reopen(); err == nil {
+		t.Fatal("Expected the reopen to fail")
+	}
+	if w.IsOpen() {
+		t.Error("Expected a failed reopen to leave the writer closed")
+	}
+	if err := w.LastError(); err == nil || !strings.Contains(err.Error(), "no more conns") {
+		t.Errorf("Expected LastError to be the dial failure, got %v", err)
+	}
+
+	w.Close()
+	if w.IsOpen() {
+		t.Error("Expected Close to leave the writer closed")
+	}
+}
+
+func TestWriteAfterFailedReopen(t *testing.T) {
+	conn := &fakeConn{}
+	w, d := newFakeWriter(t, conn)
+
+	// There are no more conns, so the dial fails
+	if err := w.Reopen(); err == nil {
+		t.Fatal("Expected the reopen to fail")
+	}
+	if w.IsOpen() || w.Conn() != nil {
+		t.Error("Expected the writer to be left disconnected")
+	}
+
+	n, err := w.Write([]byte("nowhere\n"))
+	if !errors.Is(err, ErrNotConnected) {
+		t.Errorf("Expected ErrNotConnected, got %v", err)
+	}
+	if n != 0 {
+		t.Errorf("Expected nothing written, got %d", n)
+	}
+	if _, err := w.Log("nowhere"); !errors.Is(err, ErrNotConnected) {
+		t.Errorf("Expected ErrNotConnected from Log too, got %v", err)
+	}
+
+	// Once the endpoint is back, a retry reconnects
+	healthy := &fakeConn{}
+	d.conns = append(d.conns, healthy)
+	w.MaxRetries = 1
+	if _, err := w.Write([]byte("back\n")); err != nil {
+		t.Fatal(err)
+	}
+	if len(healthy.Writes()) != 1 {
+		t.Error("Expected the write to go to the new connection")
+	}
+}
+
+func TestCloseDisconnectedWriter(t *testing.T) {
+	w, _ := newFakeWriter(t, &fakeConn{failWrites: 1, writeErr: errors.New("broken pipe")})
+	if _, err := w.Write([]byte("lost\n")); err == nil {
+		t.Fatal("Expected the write to fail")
+	}
+	if err := w.Close(); err != nil {
+		t.Errorf("Expected closing a disconnected writer to succeed, got %v", err)
+	}
+}
+
+func TestWriteAfterCloseDoesNotReconnect(t *testing.T) {
+	w, d := newFakeWriter(t, &fakeConn{}, &fakeConn{})
+	w.MaxRetries = 1
+	w.AutoReconnect = true
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := w.Log("too late"); err != ErrClosed || n != 0 {
+		t.Errorf("Expected 0 bytes and ErrClosed, got %d and %v", n, err)
+	}
+	if d.dials != 1 {
+		t.Errorf("Expected no redial after Close, got %d dials", d.dials)
+	}
+	if w.IsOpen() {
+		t.Error("Expected the writer to stay closed")
+	}
+
+	if err := w.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Log("reopened"); err != nil {
+		t.Errorf("Expected Reopen to allow writes again, got %v", err)
+	}
+}
+
+func TestClock(t *testing.T) {
+	conn := &fakeConn{}
+	w, _ := newFakeWriter(t, conn)
+	frozen := time.Date(2024, 2, 29, 12, 30, 45, 123456789, time.FixedZone("EST", -5*60*60))
+	w.clock = func() time.Time { return frozen }
+	expected := "2024-02-29T17:30:45.123456789Z"
+
+	w.Log("fast path")
+	if ts := lastEvent(t, conn)["@timestamp"]; ts != expected {
+		t.Errorf("Expected %s, got %v", expected, ts)
+	}
+	w.LogFields("with fields", map[string]interface{}{"k": "v"})
+	if ts := lastEvent(t, conn)["@timestamp"]; ts != expected {
+		t.Errorf("Expected %s with fields, got %v", expected, ts)
+	}
+
+	w.Location = time.FixedZone("EST", -5*60*60)
+	w.Log("in a location")
+	if ts := lastEvent(t, conn)["@timestamp"]; ts != "2024-02-29T12:30:45.123456789-05:00" {
+		t.Errorf("Expected the frozen time in the Location, got %v", ts)
+	}
+}
+
+func TestIncludeHost(t *testing.T) {
+	conn := &fakeConn{}
+	w, _ := newFakeWriter(t, conn)
+	if !w.IncludeHost {
+		t.Fatal("Expected the host to be included by default")
+	}
+	w.Log("with host")
+	if host := lastEvent(t, conn)["host"]; host != w.Host {
+		t.Errorf("Expected host %q, got %v", w.Host, host)
+	}
+
+	WithoutHost()(&w.baseWriter)
+	w.Log("fast path")
+	if host, ok := lastEvent(t, conn)["host"]; ok {
+		t.Errorf("Expected no host key, got %v", host)
+	}
+	w.LogFields("with fields", map[string]interface{}{"k": "v"})
+	event := lastEvent(t, conn)
+	if host, ok := event["host"]; ok {
+		t.Errorf("Expected no host key with fields, got %v", host)
+	}
+	if event["k"] != "v" || event["message"] != "with fields" {
+		t.Errorf("Expected the rest of the event to be unchanged, got %v", event)
+	}
+	w.Template = ECSTemplate
+	w.Log("ecs")
+	if host, ok := lastEvent(t, conn)["host"]; ok {
+		t.Errorf("Expected no host key from a template, got %v", host)
+	}
+}
+
+func TestMessageKey(t *testing.T) {
+	conn := &fakeConn{}
+	w, _ := newFakeWriter(t, conn)
+	WithMessageKey("log")(&w.baseWriter)
+
+	w.Log("fast path")
+	event := lastEvent(t, conn)
+	if event["log"] != "fast path" {
+		t.Errorf("Expected the message under log, got %v", event)
+	}
+	if _, ok := event["message"]; ok {
+		t.Error("Expected no message key")
+	}
+
+	w.LogFields("with fields", map[string]interface{}{"log": "collides", "message": "free"})
+	event = lastEvent(t, conn)
+	if event["log"] != "with fields" || event["fields.log"] != "collides" {
+		t.Errorf("Expected a colliding field to be prefixed, got %v", event)
+	}
+	if event["message"] != "free" {
+		t.Errorf("Expected message to be an ordinary field, got %v", event["message"])
+	}
+}
+
+// temporaryError is a net.Error which reports itself as temporary
+type temporaryError struct{}
+
+func (temporaryError) Error() string   { return "resource temporarily unavailable" }
+func (temporaryError) Timeout() bool   { return false }
+func (temporaryError) Temporary() bool { return true }
+
+func TestWriteRidesOutTemporaryErrors(t *testing.T) {
+	for _, cause := range []error{syscall.EAGAIN, temporaryError{}} {
+		conn := &fakeConn{failWrites: 2, writeErr: cause}
+		w, d := newFakeWriter(t, conn)
+
+		if _, err := w.Write([]byte("hello\n")); err != nil {
+			t.Fatalf("Expected the write to succeed after %v, got %s", cause, err)
+		}
+		if !w.IsOpen() || conn.closed || d.dials != 1 {
+			t.Errorf("Expected the connection to stay open after %v", cause)
+		}
+		if len(conn.Writes()) != 1 || w.Stats().WriteErrors != 0 {
+			t.Errorf("Expected the message to be written once, without an error, after %v", cause)
+		}
+	}
+}
+
+func TestWriteGivesUpOnPersistentTemporaryErrors(t *testing.T) {
+	conn := &fakeConn{failWrites: maxTemporaryErrors + 1, writeErr: syscall.EAGAIN}
+	w, _ := newFakeWriter(t, conn)
+
+	_, err := w.Write([]byte("hello\n"))
+	if !errors.Is(err, syscall.EAGAIN) {
+		t.Fatalf("Expected EAGAIN once the retries ran out, got %v", err)
+	}
+	if !conn.closed {
+		t.Error("Expected the connection to be closed once the retries ran out")
+	}
+}
+
+func TestWriteClosesOnPermanentErrors(t *testing.T) {
+	conn := &fakeConn{failWrites: 1, writeErr: syscall.EPIPE}
+	w, _ := newFakeWriter(t, conn)
+
+	if _, err := w.Write([]byte("hello\n")); !errors.Is(err, syscall.EPIPE) {
+		t.Fatalf("Expected EPIPE, got %v", err)
+	}
+	if !conn.closed || len(conn.Writes()) != 0 {
+		t.Error("Expected a broken pipe to close the connection without trying again")
+	}
+}
+
+func TestTimestampKey(t *testing.T) {
+	conn := &fakeConn{}
+	w, _ := newFakeWriter(t, conn)
+	WithTimestampKey("time")(&w.baseWriter)
+	frozen := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	w.clock = func() time.Time { return frozen }
+	expected := frozen.Format(DefaultTimestampFormat)
+
+	for _, log := range []func(){
+		func() { w.Log("fast path") },
+		func() { w.LogFields("with fields", map[string]interface{}{"time": "collides"}) },
+	} {
+		log()
+		event := lastEvent(t, conn)
+		if event["time"] != expected {
+			t.Errorf("Expected the timestamp under time, got %v", event)
+		}
+		for k, v := range event {
+			if k != "time" && v == expected {
+				t.Errorf("Expected the timestamp nowhere else, but found it under %s", k)
+			}
+		}
+		if _, ok := event["@timestamp"]; ok {
+			t.Error("Expected no @timestamp key")
+		}
+	}
+	if event := lastEvent(t, conn); event["fields.time"] != "collides" {
+		t.Errorf("Expected a colliding field to be prefixed, got %v", event)
+	}
 }
@@ -0,0 +1,47 @@
+// Package logopherhook provides a logrus hook which ships entries to LogStash
+// through a Logopher writer
+package logopherhook
+
+import (
+	"github.com/StabbyCutyou/Logopher"
+	"github.com/sirupsen/logrus"
+)
+
+var _ logrus.Hook = (*Hook)(nil)
+
+// Hook is a logrus.Hook which sends each entry it fires for through a
+// logopher.FieldLogger. The entry's message becomes the message field, its
+// level becomes the level field, and its data becomes fields.
+type Hook struct {
+	writer logopher.FieldLogger
+	levels []logrus.Level
+}
+
+// New creates a Hook which fires for the given levels, or for every level if
+// none are given. Install it with logger.AddHook.
+func New(w logopher.FieldLogger, levels ...logrus.Level) *Hook {
+	if len(levels) == 0 {
+		levels = logrus.AllLevels
+	}
+	return &Hook{writer: w, levels: levels}
+}
+
+// Levels returns the levels the hook fires for
+func (h *Hook) Levels() []logrus.Level {
+	return h.levels
+}
+
+// Fire sends the entry to LogStash
+func (h *Hook) Fire(entry *logrus.Entry) error {
+	fields := make(map[string]interface{}, len(entry.Data)+1)
+	for k, v := range entry.Data {
+		if err, ok := v.(error); ok {
+			// Most errors have no exported fields, and would marshal as {}
+			v = err.Error()
+		}
+		fields[k] = v
+	}
+	fields["level"] = entry.Level.String()
+	_, err := h.writer.LogFields(entry.Message, fields)
+	return err
+}
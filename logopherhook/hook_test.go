@@ -0,0 +1,82 @@
+package logopherhook
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/StabbyCutyou/Logopher"
+	"github.com/sirupsen/logrus"
+)
+
+// captureWriter is a logopher.FieldLogger which keeps each message and its
+// fields, as they would decode from the JSON sent to LogStash
+type captureWriter struct {
+	logopher.NopWriter
+	events []map[string]interface{}
+}
+
+func (c *captureWriter) LogFields(msg string, fields map[string]interface{}) (int, error) {
+	event := map[string]interface{}{"message": msg}
+	for k, v := range fields {
+		event[k] = v
+	}
+	// Round trip through JSON, the same as the real envelope would
+	data, err := json.Marshal(event)
+	if err != nil {
+		return 0, err
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return 0, err
+	}
+	c.events = append(c.events, decoded)
+	return len(data), nil
+}
+
+func newLogger(hook *Hook) *logrus.Logger {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	logger.SetLevel(logrus.DebugLevel)
+	logger.AddHook(hook)
+	return logger
+}
+
+func TestHookFire(t *testing.T) {
+	w := &captureWriter{}
+	logger := newLogger(New(w))
+
+	logger.WithFields(logrus.Fields{"user": "bob", "attempts": 3}).
+		WithError(errors.New("bad password")).
+		Warn("login failed")
+
+	if len(w.events) != 1 {
+		t.Fatalf("Expected 1 event, got %d", len(w.events))
+	}
+	event := w.events[0]
+	expected := map[string]interface{}{
+		"message":  "login failed",
+		"level":    "warning",
+		"user":     "bob",
+		"attempts": float64(3),
+		"error":    "bad password",
+	}
+	for k, v := range expected {
+		if event[k] != v {
+			t.Errorf("Expected %s to be %v, got %v", k, v, event[k])
+		}
+	}
+}
+
+func TestHookLevels(t *testing.T) {
+	w := &captureWriter{}
+	logger := newLogger(New(w, logrus.ErrorLevel))
+
+	logger.Info("ignored")
+	logger.Debug("ignored")
+	logger.Error("shipped")
+	if len(w.events) != 1 || w.events[0]["message"] != "shipped" {
+		t.Errorf("Expected only the error entry, got %v", w.events)
+	}
+}
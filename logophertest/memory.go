@@ -0,0 +1,116 @@
+// Package logophertest provides a Logopher writer for tests, which records what
+// it is sent instead of opening a socket
+package logophertest
+
+import (
+	"encoding/json"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/StabbyCutyou/Logopher"
+)
+
+var _ logopher.FieldLogger = (*MemoryWriter)(nil)
+
+// MemoryWriter is a UDPWriter whose connection is a slice in memory. Everything
+// logged through it is encoded exactly as it would be for LogStash, and kept,
+// so tests can assert what their code logged.
+type MemoryWriter struct {
+	*logopher.UDPWriter
+	conn *memoryConn
+}
+
+// NewMemoryWriter creates a MemoryWriter configured by opts, as logopher.New
+// would be. Options which replace the Dialer would take the writer off its
+// memory, and must not be used.
+func NewMemoryWriter(opts ...logopher.Option) *MemoryWriter {
+	conn := &memoryConn{}
+	dial := func(network, address string) (net.Conn, error) {
+		return conn, nil
+	}
+	w, err := logopher.New("logophertest:1", append([]logopher.Option{logopher.WithDialer(dial)}, opts...)...)
+	if err != nil {
+		// Dialing memory can't fail, so only an option can have caused this
+		panic("logophertest: " + err.Error())
+	}
+	// A datagram could be no larger than this, but memory can
+	w.MaxDatagramSize = 0
+	return &MemoryWriter{UDPWriter: w, conn: conn}
+}
+
+// Payloads returns a copy of every payload written, in order, including the
+// trailing newline of each encoded message
+func (m *MemoryWriter) Payloads() [][]byte {
+	m.conn.mu.Lock()
+	defer m.conn.mu.Unlock()
+	payloads := make([][]byte, len(m.conn.written))
+	for i, p := range m.conn.written {
+		payloads[i] = append([]byte(nil), p...)
+	}
+	return payloads
+}
+
+// Events returns every payload written, in order, parsed as a JSON event.
+// Payloads which aren't a JSON object, such as raw bytes passed to Write, are
+// returned as nil.
+func (m *MemoryWriter) Events() []map[string]interface{} {
+	payloads := m.Payloads()
+	events := make([]map[string]interface{}, len(payloads))
+	for i, p := range payloads {
+		var event map[string]interface{}
+		if json.Unmarshal(p, &event) == nil {
+			events[i] = event
+		}
+	}
+	return events
+}
+
+// Messages returns the message field of every event written, in order, as
+// named by MessageKey. A payload without one contributes an empty string.
+func (m *MemoryWriter) Messages() []string {
+	key := m.MessageKey
+	if key == "" {
+		key = logopher.DefaultMessageKey
+	}
+	events := m.Events()
+	messages := make([]string, len(events))
+	for i, event := range events {
+		messages[i], _ = event[key].(string)
+	}
+	return messages
+}
+
+// Reset forgets everything written so far
+func (m *MemoryWriter) Reset() {
+	m.conn.mu.Lock()
+	defer m.conn.mu.Unlock()
+	m.conn.written = nil
+}
+
+// memoryConn is a net.Conn which keeps every write. It lives for as long as the
+// MemoryWriter, so that what was written survives Close and Reopen.
+type memoryConn struct {
+	mu      sync.Mutex
+	written [][]byte
+}
+
+func (c *memoryConn) Write(b []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.written = append(c.written, append([]byte(nil), b...))
+	return len(b), nil
+}
+
+func (c *memoryConn) Read(b []byte) (int, error)         { return 0, net.ErrClosed }
+func (c *memoryConn) Close() error                       { return nil }
+func (c *memoryConn) LocalAddr() net.Addr                { return memoryAddr{} }
+func (c *memoryConn) RemoteAddr() net.Addr               { return memoryAddr{} }
+func (c *memoryConn) SetDeadline(t time.Time) error      { return nil }
+func (c *memoryConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *memoryConn) SetWriteDeadline(t time.Time) error { return nil }
+
+type memoryAddr struct{}
+
+func (memoryAddr) Network() string { return "memory" }
+func (memoryAddr) String() string  { return "memory" }
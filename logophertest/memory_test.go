@@ -0,0 +1,80 @@
+package logophertest
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/StabbyCutyou/Logopher"
+)
+
+// handleSignup stands in for code under test which logs through a writer
+func handleSignup(log logopher.FieldLogger, user string) {
+	log.LogFields("signup", map[string]interface{}{"user": user})
+	log.Log("welcome sent")
+}
+
+func TestMemoryWriterCapturesEvents(t *testing.T) {
+	w := NewMemoryWriter(logopher.WithHost("test-host"))
+	handleSignup(w, "bob")
+
+	if messages := w.Messages(); !reflect.DeepEqual(messages, []string{"signup", "welcome sent"}) {
+		t.Errorf("Expected both messages, got %q", messages)
+	}
+	events := w.Events()
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 events, got %d", len(events))
+	}
+	if events[0]["user"] != "bob" {
+		t.Errorf("Expected the user field to be bob, got %v", events[0]["user"])
+	}
+	if events[0]["host"] != "test-host" {
+		t.Errorf("Expected the host to be test-host, got %v", events[0]["host"])
+	}
+	if _, ok := events[1]["@timestamp"]; !ok {
+		t.Error("Expected the event to have a timestamp")
+	}
+}
+
+func TestMemoryWriterWrite(t *testing.T) {
+	w := NewMemoryWriter()
+	w.Write([]byte("not json\n"))
+
+	if payloads := w.Payloads(); len(payloads) != 1 || string(payloads[0]) != "not json\n" {
+		t.Errorf("Expected the raw payload, got %q", payloads)
+	}
+	if events := w.Events(); len(events) != 1 || events[0] != nil {
+		t.Errorf("Expected a nil event for a payload that isn't JSON, got %v", events)
+	}
+	if messages := w.Messages(); len(messages) != 1 || messages[0] != "" {
+		t.Errorf("Expected an empty message, got %q", messages)
+	}
+}
+
+func TestMemoryWriterSurvivesReopen(t *testing.T) {
+	w := NewMemoryWriter()
+	w.Log("before")
+	if err := w.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	w.Log("after")
+
+	if messages := w.Messages(); !reflect.DeepEqual(messages, []string{"before", "after"}) {
+		t.Errorf("Expected both messages, got %q", messages)
+	}
+
+	w.Reset()
+	if messages := w.Messages(); len(messages) != 0 {
+		t.Errorf("Expected Reset to forget everything, got %q", messages)
+	}
+}
+
+func TestMemoryWriterLargeMessage(t *testing.T) {
+	w := NewMemoryWriter()
+	big := make([]byte, 100000)
+	for i := range big {
+		big[i] = 'x'
+	}
+	if _, err := w.Log(string(big)); err != nil {
+		t.Errorf("Expected a message larger than a datagram to be kept, got %v", err)
+	}
+}
@@ -0,0 +1,25 @@
+// Package logopherzap provides a zap core which ships entries to LogStash
+// through a Logopher writer
+package logopherzap
+
+import (
+	"github.com/StabbyCutyou/Logopher"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// EncoderConfig returns zap's production encoder configuration, with the time
+// and message under the keys LogStash expects
+func EncoderConfig() zapcore.EncoderConfig {
+	config := zap.NewProductionEncoderConfig()
+	config.TimeKey = "@timestamp"
+	config.MessageKey = "message"
+	config.EncodeTime = zapcore.RFC3339NanoTimeEncoder
+	return config
+}
+
+// NewCore creates a zap core which encodes each entry enabled by level as JSON,
+// using EncoderConfig, and sends it through w. Wrap it with zap.New.
+func NewCore(w logopher.WriteSyncer, level zapcore.LevelEnabler) zapcore.Core {
+	return zapcore.NewCore(zapcore.NewJSONEncoder(EncoderConfig()), w, level)
+}
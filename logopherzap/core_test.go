@@ -0,0 +1,38 @@
+package logopherzap
+
+import (
+	"testing"
+
+	"github.com/StabbyCutyou/Logopher/logophertest"
+	"go.uber.org/zap"
+)
+
+func TestNewCore(t *testing.T) {
+	w := logophertest.NewMemoryWriter()
+	logger := zap.New(NewCore(w, zap.InfoLevel))
+
+	logger.Debug("ignored")
+	logger.Info("login failed", zap.String("user", "bob"))
+	if err := logger.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	events := w.Events()
+	if len(events) != 1 {
+		t.Fatalf("Expected 1 event, got %d", len(events))
+	}
+	event := events[0]
+	expected := map[string]interface{}{
+		"message": "login failed",
+		"level":   "info",
+		"user":    "bob",
+	}
+	for k, v := range expected {
+		if event[k] != v {
+			t.Errorf("Expected %s to be %v, got %v", k, v, event[k])
+		}
+	}
+	if _, ok := event["@timestamp"]; !ok {
+		t.Error("Expected a @timestamp field")
+	}
+}
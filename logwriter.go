@@ -0,0 +1,43 @@
+package logopher
+
+import (
+	"bytes"
+	"io"
+)
+
+var _ io.Writer = (*LogWriter)(nil)
+
+// LogWriter adapts a Writer into an io.Writer that wraps everything written to
+// it in the LogStash JSON envelope, which makes it suitable for log.SetOutput
+// or log.New. By contrast, calling Write on the Writer itself sends the bytes
+// as-is, and is meant for payloads which are already formatted.
+//
+// Each call to Write becomes a single event, with any trailing newline removed.
+// The standard log package makes exactly one call per line it logs. Since
+// LogStash adds its own @timestamp, you will usually want to create the
+// *log.Logger with flags of 0.
+type LogWriter struct {
+	writer Writer
+}
+
+// NewLogWriter creates a LogWriter which logs through w
+func NewLogWriter(w Writer) *LogWriter {
+	return &LogWriter{writer: w}
+}
+
+// AsLogWriter returns an io.Writer which wraps each line written to it in the
+// LogStash JSON envelope before sending it through this writer
+func (u *baseWriter) AsLogWriter() *LogWriter {
+	return NewLogWriter(u)
+}
+
+// Write logs p as a single message. In keeping with the io.Writer contract, it
+// reports len(p) bytes written on success, rather than the size of the payload
+// that was actually sent.
+func (l *LogWriter) Write(p []byte) (int, error) {
+	msg := bytes.TrimSuffix(p, []byte("\n"))
+	if _, err := l.writer.Log(string(msg)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,43 @@
+package logopher
+
+import (
+	"encoding/json"
+	"log"
+	"testing"
+)
+
+func TestLogWriterWithStandardLogger(t *testing.T) {
+	conn := &fakeConn{}
+	w, _ := newFakeWriter(t, conn)
+
+	logger := log.New(w.AsLogWriter(), "[app] ", 0)
+	logger.Printf("user %d logged in", 7)
+	logger.Print("multi\nline")
+
+	writes := conn.Writes()
+	if len(writes) != 2 {
+		t.Fatalf("Expected 2 events, got %d", len(writes))
+	}
+	for i, expected := range []string{"[app] user 7 logged in", "[app] multi\nline"} {
+		var event map[string]string
+		if err := json.Unmarshal(writes[i], &event); err != nil {
+			t.Fatal(err)
+		}
+		if event["message"] != expected {
+			t.Errorf("Expected message %q, got %q", expected, event["message"])
+		}
+	}
+}
+
+func TestLogWriterReportsInputLength(t *testing.T) {
+	w, _ := newFakeWriter(t, &fakeConn{})
+
+	input := []byte("short\n")
+	n, err := w.AsLogWriter().Write(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(input) {
+		t.Errorf("Expected %d, got %d", len(input), n)
+	}
+}
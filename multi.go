@@ -0,0 +1,103 @@
+package logopher
+
+import "errors"
+
+var _ FieldLogger = (*MultiWriter)(nil)
+
+// MultiWriter sends every message to each of several writers, such as to
+// deliver the same logs to two independent LogStash clusters. A failure on one
+// writer doesn't stop the message going to the others: every writer is tried,
+// and their errors are joined.
+type MultiWriter struct {
+	writers []Writer
+}
+
+// NewMultiWriter creates a MultiWriter which sends to every one of writers
+func NewMultiWriter(writers ...Writer) *MultiWriter {
+	return &MultiWriter{writers: append([]Writer(nil), writers...)}
+}
+
+// Log logs msg through every writer. The byte count returned is the smallest
+// reported by any of them.
+func (m *MultiWriter) Log(msg string) (int, error) {
+	return m.LogFields(msg, nil)
+}
+
+// LogFields logs msg and fields through every writer, each building its own
+// payload, so that each sends its own host and default fields. The byte count
+// returned is the smallest reported by any of them.
+func (m *MultiWriter) LogFields(msg string, fields map[string]interface{}) (int, error) {
+	return m.each(func(w Writer) (int, error) {
+		if fl, ok := w.(FieldLogger); ok {
+			return fl.LogFields(msg, fields)
+		}
+		data, err := encodeFor(w, msg, fields)
+		if err != nil {
+			return 0, unlessSampledOut(err)
+		}
+		return w.Write(data)
+	})
+}
+
+// Write writes rawBytes to every writer. The byte count returned is the
+// smallest reported by any of them.
+func (m *MultiWriter) Write(rawBytes []byte) (int, error) {
+	return m.each(func(w Writer) (int, error) {
+		return w.Write(rawBytes)
+	})
+}
+
+// each calls write for every writer, returning the smallest byte count and all
+// of the errors
+func (m *MultiWriter) each(write func(Writer) (int, error)) (int, error) {
+	var errs []error
+	least := -1
+	for _, w := range m.writers {
+		n, err := write(w)
+		if least < 0 || n < least {
+			least = n
+		}
+		errs = append(errs, err)
+	}
+	if least < 0 {
+		least = 0
+	}
+	return least, errors.Join(errs...)
+}
+
+// encode builds payloads the same way the first writer would
+func (m *MultiWriter) encode(msg string, fields map[string]interface{}) ([]byte, error) {
+	if len(m.writers) == 0 {
+		return encodeFor(nil, msg, fields)
+	}
+	return encodeFor(m.writers[0], msg, fields)
+}
+
+// Flush flushes every writer which buffers messages, returning all of the
+// errors encountered
+func (m *MultiWriter) Flush() error {
+	var errs []error
+	for _, w := range m.writers {
+		errs = append(errs, flushWriter(w))
+	}
+	return errors.Join(errs...)
+}
+
+// Reopen re-establishes every writer's connection, returning all of the errors
+// encountered
+func (m *MultiWriter) Reopen() error {
+	var errs []error
+	for _, w := range m.writers {
+		errs = append(errs, w.Reopen())
+	}
+	return errors.Join(errs...)
+}
+
+// Close closes every writer, returning all of the errors encountered
+func (m *MultiWriter) Close() error {
+	var errs []error
+	for _, w := range m.writers {
+		errs = append(errs, w.Close())
+	}
+	return errors.Join(errs...)
+}
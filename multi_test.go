@@ -0,0 +1,53 @@
+package logopher
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestMultiWriterSendsToAll(t *testing.T) {
+	first, second := &recordingWriter{}, &recordingWriter{}
+	m := NewMultiWriter(first, second)
+
+	for _, msg := range []string{"one", "two"} {
+		if _, err := m.Log(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := m.Write([]byte("{\"message\":\"three\"}\n")); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"one", "two", "three"}
+	for i, r := range []*recordingWriter{first, second} {
+		if got := r.Messages(t); !reflect.DeepEqual(got, expected) {
+			t.Errorf("Writer %d: Expected %v, got %v", i, expected, got)
+		}
+	}
+
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !first.closed || !second.closed {
+		t.Error("Expected every writer to be closed")
+	}
+}
+
+func TestMultiWriterTriesEveryWriter(t *testing.T) {
+	firstErr, thirdErr := errors.New("cluster a is down"), errors.New("cluster c is down")
+	first := &recordingWriter{writeErr: firstErr}
+	second := &recordingWriter{}
+	third := &recordingWriter{writeErr: thirdErr}
+	m := NewMultiWriter(first, second, third)
+
+	n, err := m.Log("important")
+	if !errors.Is(err, firstErr) || !errors.Is(err, thirdErr) {
+		t.Errorf("Expected both failures to surface, got %v", err)
+	}
+	if n != 0 {
+		t.Errorf("Expected the smallest byte count, 0, got %d", n)
+	}
+	if got := second.Messages(t); !reflect.DeepEqual(got, []string{"important"}) {
+		t.Errorf("Expected the healthy writer to still get the message, got %v", got)
+	}
+}
@@ -0,0 +1,33 @@
+package logopher
+
+var _ FieldLogger = NopWriter{}
+
+// NopWriter is a Writer which discards everything, the logging equivalent of
+// io.Discard. It is useful where logging is disabled, or in tests, since it
+// never touches the network.
+type NopWriter struct{}
+
+// Log discards msg, reporting len(msg) bytes written
+func (NopWriter) Log(msg string) (int, error) {
+	return len(msg), nil
+}
+
+// LogFields discards msg and fields, reporting len(msg) bytes written
+func (NopWriter) LogFields(msg string, fields map[string]interface{}) (int, error) {
+	return len(msg), nil
+}
+
+// Write discards rawBytes, reporting len(rawBytes) bytes written
+func (NopWriter) Write(rawBytes []byte) (int, error) {
+	return len(rawBytes), nil
+}
+
+// Close does nothing
+func (NopWriter) Close() error {
+	return nil
+}
+
+// Reopen does nothing
+func (NopWriter) Reopen() error {
+	return nil
+}
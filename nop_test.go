@@ -0,0 +1,20 @@
+package logopher
+
+import "testing"
+
+func TestNopWriter(t *testing.T) {
+	var w Writer = NopWriter{}
+
+	if n, err := w.Log("hello"); n != 5 || err != nil {
+		t.Errorf("Expected (5, nil), got (%d, %v)", n, err)
+	}
+	if n, err := w.Write([]byte("hello\n")); n != 6 || err != nil {
+		t.Errorf("Expected (6, nil), got (%d, %v)", n, err)
+	}
+	if err := w.Reopen(); err != nil {
+		t.Error(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,240 @@
+package logopher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// Option configures a writer created by New. Options are applied in order,
+// before the connection is dialed.
+type Option func(*baseWriter)
+
+// New creates a UDPWriter for address, configured by opts. With no options it
+// behaves the same as DialUDP(address, false).
+func New(address string, opts ...Option) (*UDPWriter, error) {
+	return dialUDP("udp", address, opts)
+}
+
+// WithLogging reports connection problems through the standard logger
+func WithLogging(enabled bool) Option {
+	return func(u *baseWriter) {
+		u.enableLogging = enabled
+	}
+}
+
+// WithLogger sends the writer's diagnostics to l, rather than the standard
+// logger
+func WithLogger(l Logger) Option {
+	return func(u *baseWriter) {
+		u.Logger = l
+	}
+}
+
+// WithWriteTimeout sets the WriteTimeout
+func WithWriteTimeout(d time.Duration) Option {
+	return func(u *baseWriter) {
+		u.WriteTimeout = d
+	}
+}
+
+// WithCloseTimeout sets the CloseTimeout
+func WithCloseTimeout(d time.Duration) Option {
+	return func(u *baseWriter) {
+		u.CloseTimeout = d
+	}
+}
+
+// WithTerminator sets the Terminator appended to every message
+func WithTerminator(terminator []byte) Option {
+	return func(u *baseWriter) {
+		u.Terminator = terminator
+	}
+}
+
+// WithHost sets the Host sent with every message, in place of the hostname
+func WithHost(host string) Option {
+	return func(u *baseWriter) {
+		u.Host = host
+	}
+}
+
+// WithMessageKey sets the MessageKey the message is sent in
+func WithMessageKey(key string) Option {
+	return func(u *baseWriter) {
+		u.MessageKey = key
+	}
+}
+
+// WithTimestampKey sets the TimestampKey the timestamp is sent in
+func WithTimestampKey(key string) Option {
+	return func(u *baseWriter) {
+		u.TimestampKey = key
+	}
+}
+
+// WithoutHost leaves the host field out of every message. See IncludeHost.
+func WithoutHost() Option {
+	return func(u *baseWriter) {
+		u.IncludeHost = false
+	}
+}
+
+// WithMaxRetries sets MaxRetries
+func WithMaxRetries(n int) Option {
+	return func(u *baseWriter) {
+		u.MaxRetries = n
+	}
+}
+
+// WithDefaultFields sets the DefaultFields included in every message. The map
+// is copied, so changing it afterwards has no effect on the writer.
+func WithDefaultFields(fields map[string]interface{}) Option {
+	return func(u *baseWriter) {
+		u.DefaultFields = cloneFields(fields)
+	}
+}
+
+// ErrDialTimeout is returned when a dial limited by WithDialTimeout or
+// DialUDPTimeout runs out of time
+var ErrDialTimeout = errors.New("logopher: timed out dialing")
+
+// dialResolver, if set, is used by WithDialTimeout in place of the default
+// resolver. It is a variable so tests can replace it.
+var dialResolver *net.Resolver
+
+// WithDialTimeout limits how long resolving and dialing the address may take,
+// for the first connection and every reconnect. It replaces the Dialer.
+func WithDialTimeout(timeout time.Duration) Option {
+	return func(u *baseWriter) {
+		u.netDialer().Timeout = timeout
+	}
+}
+
+// WithLocalAddr dials every connection from addr, such as a *net.UDPAddr, in
+// place of an address and ephemeral port chosen by the system. The address
+// must suit the network, so a udp writer needs a *net.UDPAddr. It replaces the
+// Dialer.
+func WithLocalAddr(addr net.Addr) Option {
+	return func(u *baseWriter) {
+		u.netDialer().LocalAddr = addr
+	}
+}
+
+// netDialer returns the net.Dialer which options such as WithDialTimeout and
+// WithLocalAddr configure, installing it as the Dialer if it isn't already, so
+// that those options combine rather than replace each other
+func (u *baseWriter) netDialer() *net.Dialer {
+	if u.dialer != nil {
+		return u.dialer
+	}
+	dialer := &net.Dialer{Resolver: dialResolver}
+	u.dialer = dialer
+	u.Dialer = func(network, address string) (net.Conn, error) {
+		conn, err := dialer.Dial(network, address)
+		if err != nil && dialer.Timeout > 0 && isTimeout(err) {
+			return nil, fmt.Errorf("%w %s after %s: %w", ErrDialTimeout, address, dialer.Timeout, err)
+		}
+		return conn, err
+	}
+	return dialer
+}
+
+// WithDialer sets the Dialer, which is also used for the initial connection
+func WithDialer(dial func(network, address string) (net.Conn, error)) Option {
+	return func(u *baseWriter) {
+		u.Dialer = dial
+		u.dialer = nil
+	}
+}
+
+// WithSequence numbers every message logged, as described for Sequence
+func WithSequence() Option {
+	return func(u *baseWriter) {
+		u.Sequence = true
+	}
+}
+
+// WithVerifyOnDial makes New Ping the endpoint before returning, and fail if it
+// is unreachable. Dialing udp involves no handshake, so without it a dead
+// endpoint goes unnoticed until writes start to fail, if they ever do. The
+// check takes up to a quarter of a second.
+func WithVerifyOnDial() Option {
+	return func(u *baseWriter) {
+		u.verifyOnDial = true
+	}
+}
+
+// WithReplayBuffer holds on to up to n failed messages for replay, as
+// described for ReplayBufferSize
+func WithReplayBuffer(n int) Option {
+	return func(u *baseWriter) {
+		u.ReplayBufferSize = n
+	}
+}
+
+// WithSource records where every message was logged from, as described for
+// AddSource
+func WithSource() Option {
+	return func(u *baseWriter) {
+		u.AddSource = true
+	}
+}
+
+// WithRedactor sets the Redactor applied to every message, and to string field
+// values too if fields is true
+func WithRedactor(redact func(string) string, fields bool) Option {
+	return func(u *baseWriter) {
+		u.Redactor = redact
+		u.RedactFields = fields
+	}
+}
+
+// WithSampler sets the Sampler which decides which messages are sent
+func WithSampler(sampler Sampler) Option {
+	return func(u *baseWriter) {
+		u.Sampler = sampler
+	}
+}
+
+// WithECS renders messages in the Elastic Common Schema, by setting the
+// Template to ECSTemplate
+func WithECS() Option {
+	return func(u *baseWriter) {
+		u.Template = ECSTemplate
+	}
+}
+
+// WithMaxMessageBytes sets MaxMessageBytes
+func WithMaxMessageBytes(n int) Option {
+	return func(u *baseWriter) {
+		u.MaxMessageBytes = n
+	}
+}
+
+// WithContextExtractor sets the ContextExtractor which finds fields in the
+// context passed to LogContext
+func WithContextExtractor(extract func(ctx context.Context) map[string]interface{}) Option {
+	return func(u *baseWriter) {
+		u.ContextExtractor = extract
+	}
+}
+
+// WithAck makes every write wait until ack is read back from the connection,
+// for up to timeout, or DefaultAckTimeout if it is zero. See Ack.
+func WithAck(ack []byte, timeout time.Duration) Option {
+	return func(u *baseWriter) {
+		u.Ack = ack
+		u.AckTimeout = timeout
+	}
+}
+
+// WithIdleReconnect replaces the connection once it has gone idle for d. See
+// IdleReconnect.
+func WithIdleReconnect(d time.Duration) Option {
+	return func(u *baseWriter) {
+		u.IdleReconnect = d
+	}
+}
@@ -0,0 +1,200 @@
+package logopher
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestNewWithoutOptions(t *testing.T) {
+	l := listenUDP(t)
+	w, err := New(l.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	if w.enableLogging || w.MaxRetries != 0 || w.WriteTimeout != 0 || w.DefaultFields != nil {
+		t.Error("Expected New without options to match DialUDP")
+	}
+	if w.Host != resolveHost() {
+		t.Errorf("Expected the host to default to %q, got %q", resolveHost(), w.Host)
+	}
+	if _, err := w.Log("hello"); err != nil {
+		t.Fatal(err)
+	}
+	readDatagram(t, l)
+}
+
+func TestNewWithOptions(t *testing.T) {
+	fields := map[string]interface{}{"service": "billing"}
+	tests := []struct {
+		name string
+		opts []Option
+		want func(w *UDPWriter) error
+	}{
+		{
+			name: "logging and timeout",
+			opts: []Option{WithLogging(true), WithWriteTimeout(time.Second)},
+			want: func(w *UDPWriter) error {
+				if !w.enableLogging || w.WriteTimeout != time.Second {
+					return errors.New("expected logging and a one second timeout")
+				}
+				return nil
+			},
+		},
+		{
+			name: "host and fields",
+			opts: []Option{WithHost("pod-7"), WithDefaultFields(fields)},
+			want: func(w *UDPWriter) error {
+				if w.Host != "pod-7" || w.DefaultFields["service"] != "billing" {
+					return errors.New("expected the host and default fields to be set")
+				}
+				return nil
+			},
+		},
+		{
+			name: "later options win",
+			opts: []Option{WithMaxRetries(1), WithHost("first"), WithMaxRetries(3), WithHost("second")},
+			want: func(w *UDPWriter) error {
+				if w.MaxRetries != 3 || w.Host != "second" {
+					return errors.New("expected the last MaxRetries and Host to be kept")
+				}
+				return nil
+			},
+		},
+	}
+	for _, test := range tests {
+		d := &fakeDialer{conns: []*fakeConn{{}}}
+		w, err := New("fake:5000", append(test.opts, WithDialer(d.dial))...)
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		if d.dials != 1 {
+			t.Errorf("%s: Expected the dialer option to be used for the first connection", test.name)
+		}
+		if err := test.want(w); err != nil {
+			t.Errorf("%s: %s", test.name, err)
+		}
+	}
+}
+
+func TestWithDefaultFieldsCopiesTheMap(t *testing.T) {
+	fields := map[string]interface{}{"env": "prod"}
+	conn := &fakeConn{}
+	d := &fakeDialer{conns: []*fakeConn{conn}}
+	w, err := New("fake:5000", WithDialer(d.dial), WithDefaultFields(fields))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fields["env"] = "staging"
+	w.Log("hello")
+	if env := lastEvent(t, conn)["env"]; env != "prod" {
+		t.Errorf("Expected env prod, got %v", env)
+	}
+}
+
+func TestNewInvalidAddress(t *testing.T) {
+	if _, err := New("bad host:5000", WithLogging(true)); err == nil {
+		t.Error("Expected an invalid address to be rejected")
+	}
+}
+
+func TestWithVerifyOnDial(t *testing.T) {
+	l := listenUDP(t)
+	if w, err := New(l.LocalAddr().String(), WithVerifyOnDial()); err != nil {
+		t.Errorf("Expected a live listener to pass, got %v", err)
+	} else {
+		w.Close()
+	}
+
+	closed := listenUDP(t)
+	address := closed.LocalAddr().String()
+	closed.Close()
+	w, err := New(address)
+	if err != nil {
+		t.Fatalf("Expected the default to dial a closed port without complaint, got %v", err)
+	}
+	w.Close()
+	if _, err := New(address, WithVerifyOnDial()); err == nil {
+		t.Error("Expected verifying a closed port to fail")
+	}
+}
+
+func TestDialUDPTimeout(t *testing.T) {
+	// A DNS server which never answers
+	blackhole := listenUDP(t)
+	dialResolver = &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "udp", blackhole.LocalAddr().String())
+		},
+	}
+	defer func() { dialResolver = nil }()
+
+	start := time.Now()
+	_, err := DialUDPTimeout("logstash.invalid:5000", 100*time.Millisecond, false)
+	if !errors.Is(err, ErrDialTimeout) {
+		t.Fatalf("Expected ErrDialTimeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected to give up after about 100ms, took %s", elapsed)
+	}
+}
+
+func TestDialUDPTimeoutSucceeds(t *testing.T) {
+	l := listenUDP(t)
+	w, err := DialUDPTimeout(l.LocalAddr().String(), time.Second, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if _, err := w.Log("in time"); err != nil {
+		t.Fatal(err)
+	}
+	readDatagram(t, l)
+}
+
+// freeUDPPort returns a local udp address which was free a moment ago
+func freeUDPPort(t *testing.T) *net.UDPAddr {
+	l := listenUDP(t)
+	addr := l.LocalAddr().(*net.UDPAddr)
+	l.Close()
+	return addr
+}
+
+func TestWithLocalAddr(t *testing.T) {
+	server := listenUDP(t)
+	local := freeUDPPort(t)
+
+	w, err := New(server.LocalAddr().String(), WithLocalAddr(local), WithDialTimeout(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if got := w.Conn().LocalAddr().String(); got != local.String() {
+		t.Errorf("Expected the connection to be bound to %s, got %s", local, got)
+	}
+
+	w.Log("from a fixed port")
+	buf := make([]byte, 65536)
+	server.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, from, err := server.ReadFromUDP(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from.Port != local.Port {
+		t.Errorf("Expected the datagram to come from port %d, got %d", local.Port, from.Port)
+	}
+
+	if err := w.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	if got := w.Conn().LocalAddr().String(); got != local.String() {
+		t.Errorf("Expected the reopened connection to be bound to %s too, got %s", local, got)
+	}
+}
@@ -0,0 +1,89 @@
+package logopher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+)
+
+// udpPingWait is how long Ping listens for a udp probe to be refused, when the
+// context doesn't set a shorter deadline
+const udpPingWait = 250 * time.Millisecond
+
+// Ping checks that LogStash is reachable, returning an error describing why if
+// it isn't, which makes it suitable for readiness probes. For tcp and tls, Ping
+// dials a separate connection, leaving the one used for writing untouched. For
+// udp, which has no handshake, Ping sends an empty datagram from a separately
+// dialed socket and listens briefly for the remote host to refuse it, so
+// logging isn't held up while it waits. A udp endpoint which silently drops
+// traffic can't be told apart from a healthy one.
+func (u *baseWriter) Ping(ctx context.Context) error {
+	var err error
+	if u.datagram {
+		err = u.pingDatagram(ctx)
+	} else {
+		err = u.pingStream(ctx)
+	}
+	if err != nil {
+		return fmt.Errorf("logopher: %s is unreachable: %w", u.address, err)
+	}
+	return nil
+}
+
+// pingStream dials a fresh connection, giving up when ctx is done
+func (u *baseWriter) pingStream(ctx context.Context) error {
+	type result struct {
+		conn net.Conn
+		err  error
+	}
+	dialed := make(chan result, 1)
+	go func() {
+		conn, err := u.connect()
+		dialed <- result{conn, err}
+	}()
+	select {
+	case r := <-dialed:
+		if r.err != nil {
+			return r.err
+		}
+		return r.conn.Close()
+	case <-ctx.Done():
+		go func() {
+			// Clean up after the abandoned dial, whenever it finishes
+			if r := <-dialed; r.err == nil {
+				r.conn.Close()
+			}
+		}()
+		return ctx.Err()
+	}
+}
+
+// pingDatagram sends an empty datagram on a fresh socket, and waits to see if
+// it is refused
+func (u *baseWriter) pingDatagram(ctx context.Context) error {
+	conn, err := u.connect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write(nil); err != nil {
+		return err
+	}
+	deadline := time.Now().Add(udpPingWait)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetReadDeadline(deadline)
+
+	// Nothing is expected to arrive, so a timeout is the healthy outcome. A
+	// refusal from the remote host is reported as an error on the read.
+	_, err = conn.Read(make([]byte, 1))
+	if err == nil || errors.Is(err, os.ErrDeadlineExceeded) {
+		return ctx.Err()
+	}
+	return err
+}
@@ -0,0 +1,95 @@
+package logopher
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestPingUDP(t *testing.T) {
+	l := listenUDP(t)
+	w, err := DialUDP(l.LocalAddr().String(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	if err := w.Ping(context.Background()); err != nil {
+		t.Errorf("Expected a live listener to pass, got %v", err)
+	}
+}
+
+func TestPingUDPDoesNotBlockLogging(t *testing.T) {
+	l := listenUDP(t)
+	w, err := DialUDP(l.LocalAddr().String(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	pinged := make(chan error, 1)
+	go func() { pinged <- w.Ping(context.Background()) }()
+	time.Sleep(50 * time.Millisecond)
+
+	start := time.Now()
+	if _, err := w.Log("during the ping"); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > udpPingWait/2 {
+		t.Errorf("Expected logging to carry on during a ping, took %s", elapsed)
+	}
+	if err := <-pinged; err != nil {
+		t.Errorf("Expected a live listener to pass, got %v", err)
+	}
+}
+
+func TestPingUDPClosedPort(t *testing.T) {
+	l := listenUDP(t)
+	address := l.LocalAddr().String()
+	l.Close()
+	w, err := DialUDP(address, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	if err := w.Ping(context.Background()); err == nil {
+		t.Error("Expected a closed port to fail")
+	}
+}
+
+func TestPingTCP(t *testing.T) {
+	l, _ := listenTCP(t)
+	w, err := DialTCP(l.Addr().String(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	if err := w.Ping(context.Background()); err != nil {
+		t.Errorf("Expected a live listener to pass, got %v", err)
+	}
+	l.Close()
+	if err := w.Ping(context.Background()); err == nil {
+		t.Error("Expected a closed port to fail")
+	}
+}
+
+func TestPingContext(t *testing.T) {
+	w, _ := newFakeWriter(t, &fakeConn{})
+	w.Dialer = func(network, address string) (net.Conn, error) {
+		time.Sleep(time.Second)
+		return &fakeConn{}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := w.Ping(ctx); err == nil {
+		t.Error("Expected the ping to give up")
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Expected the ping to give up promptly, took %s", elapsed)
+	}
+}
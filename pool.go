@@ -0,0 +1,93 @@
+package logopher
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+var _ FieldLogger = (*Pool)(nil)
+
+// Pool holds several writers to the same address and spreads messages across
+// them round-robin, so that goroutines logging concurrently aren't all waiting
+// on one connection
+type Pool struct {
+	members []Writer
+	next    atomic.Uint64
+}
+
+// NewPool dials size writers to address using dial, for example:
+//
+//	pool, err := logopher.NewPool(address, 4, func(address string) (logopher.Writer, error) {
+//		return logopher.DialTCP(address, false)
+//	})
+//
+// If any dial fails, the writers already dialed are closed and the error is
+// returned.
+func NewPool(address string, size int, dial func(string) (Writer, error)) (*Pool, error) {
+	if size < 1 {
+		return nil, errors.New("logopher: pool size must be at least 1")
+	}
+	p := &Pool{}
+	for i := 0; i < size; i++ {
+		w, err := dial(address)
+		if err != nil {
+			p.Close()
+			return nil, err
+		}
+		p.members = append(p.members, w)
+	}
+	return p, nil
+}
+
+// member picks the next writer in the rotation
+func (p *Pool) member() Writer {
+	n := p.next.Add(1) - 1
+	return p.members[n%uint64(len(p.members))]
+}
+
+// Log logs msg through the next writer in the pool
+func (p *Pool) Log(msg string) (int, error) {
+	return p.member().Log(msg)
+}
+
+// LogFields logs msg and fields through the next writer in the pool
+func (p *Pool) LogFields(msg string, fields map[string]interface{}) (int, error) {
+	w := p.member()
+	if fl, ok := w.(FieldLogger); ok {
+		return fl.LogFields(msg, fields)
+	}
+	data, err := encodeFor(w, msg, fields)
+	if err != nil {
+		return 0, unlessSampledOut(err)
+	}
+	return w.Write(data)
+}
+
+// Write writes rawBytes through the next writer in the pool
+func (p *Pool) Write(rawBytes []byte) (int, error) {
+	return p.member().Write(rawBytes)
+}
+
+// encode builds payloads the same way the pooled writers would
+func (p *Pool) encode(msg string, fields map[string]interface{}) ([]byte, error) {
+	return encodeFor(p.members[0], msg, fields)
+}
+
+// Reopen re-establishes every pooled writer's connection, returning all of the
+// errors encountered
+func (p *Pool) Reopen() error {
+	var errs []error
+	for _, w := range p.members {
+		errs = append(errs, w.Reopen())
+	}
+	return errors.Join(errs...)
+}
+
+// Close closes every pooled writer, returning all of the errors encountered
+func (p *Pool) Close() error {
+	var errs []error
+	for _, w := range p.members {
+		errs = append(errs, w.Close())
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,97 @@
+package logopher
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestPoolRoundRobin(t *testing.T) {
+	var members []*recordingWriter
+	p, err := NewPool("fake:5000", 4, func(address string) (Writer, error) {
+		r := &recordingWriter{}
+		members = append(members, r)
+		return r, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const count = 400
+	var wg sync.WaitGroup
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, err := p.Log(fmt.Sprintf("message %d", i)); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	seen := make(map[string]bool)
+	for i, r := range members {
+		messages := r.Messages(t)
+		if len(messages) != count/4 {
+			t.Errorf("Expected member %d to get %d messages, got %d", i, count/4, len(messages))
+		}
+		for _, msg := range messages {
+			seen[msg] = true
+		}
+	}
+	if len(seen) != count {
+		t.Errorf("Expected %d distinct messages, got %d", count, len(seen))
+	}
+
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for i, r := range members {
+		if !r.closed {
+			t.Errorf("Expected member %d to be closed", i)
+		}
+	}
+}
+
+func TestPoolOverTCP(t *testing.T) {
+	l, lines := listenTCP(t)
+	p, err := NewPool(l.Addr().String(), 3, func(address string) (Writer, error) {
+		return DialTCP(address, false)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	for i := 0; i < 9; i++ {
+		if _, err := p.LogFields("pooled", map[string]interface{}{"i": i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 9; i++ {
+		readLine(t, lines)
+	}
+}
+
+func TestPoolDialFailure(t *testing.T) {
+	var dialed []*recordingWriter
+	dialErr := errors.New("connection refused")
+	_, err := NewPool("fake:5000", 3, func(address string) (Writer, error) {
+		if len(dialed) == 2 {
+			return nil, dialErr
+		}
+		r := &recordingWriter{}
+		dialed = append(dialed, r)
+		return r, nil
+	})
+	if err != dialErr {
+		t.Errorf("Expected %v, got %v", dialErr, err)
+	}
+	for i, r := range dialed {
+		if !r.closed {
+			t.Errorf("Expected writer %d to be closed after the failure", i)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package logopher
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// PIDField and ProgramField are the fields WithPID and WithProgram send the
+// process ID and program name in
+const (
+	PIDField     = "pid"
+	ProgramField = "program"
+)
+
+// WithPID adds a pid field to every message, with the ID of the current
+// process, so that logs from before and after a restart can be told apart
+func WithPID() Option {
+	return func(u *baseWriter) {
+		u.setProcessField(PIDField, os.Getpid())
+	}
+}
+
+// WithProgram adds a program field to every message, with the name the
+// program was run as, from os.Args[0] without its directory
+func WithProgram() Option {
+	return func(u *baseWriter) {
+		if len(os.Args) > 0 {
+			u.setProcessField(ProgramField, filepath.Base(os.Args[0]))
+		}
+	}
+}
+
+// setProcessField sets one of the fields describing the process, which are
+// looked up once, when the writer is created
+func (u *baseWriter) setProcessField(key string, value interface{}) {
+	if u.processFields == nil {
+		u.processFields = make(map[string]interface{})
+	}
+	u.processFields[key] = value
+}
@@ -0,0 +1,39 @@
+package logopher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProcessFields(t *testing.T) {
+	conn := &fakeConn{}
+	w, _ := newFakeWriter(t, conn)
+	w.Log("without")
+	event := lastEvent(t, conn)
+	if _, ok := event[PIDField]; ok {
+		t.Error("Expected no pid unless asked for")
+	}
+
+	WithPID()(&w.baseWriter)
+	WithProgram()(&w.baseWriter)
+	WithDefaultFields(map[string]interface{}{"service": "auth"})(&w.baseWriter)
+	w.Log("fast path")
+	event = lastEvent(t, conn)
+	if pid := event[PIDField]; pid != float64(os.Getpid()) {
+		t.Errorf("Expected pid %d, got %v", os.Getpid(), pid)
+	}
+	program, _ := event[ProgramField].(string)
+	if program == "" || program != filepath.Base(os.Args[0]) || filepath.Base(program) != program {
+		t.Errorf("Expected the program name %s, got %q", filepath.Base(os.Args[0]), program)
+	}
+	if event["service"] != "auth" {
+		t.Errorf("Expected the default fields to be kept, got %v", event)
+	}
+
+	w.LogFields("override", map[string]interface{}{ProgramField: "custom"})
+	event = lastEvent(t, conn)
+	if event[ProgramField] != "custom" || event[PIDField] != float64(os.Getpid()) {
+		t.Errorf("Expected a field passed to LogFields to take precedence, got %v", event)
+	}
+}
@@ -0,0 +1,122 @@
+package logopher
+
+import (
+	"bufio"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// listenProxy opens a minimal HTTP proxy, which answers CONNECT by tunneling to
+// the requested address and refuses anything without the expected
+// Proxy-Authorization, if one is given. Each CONNECT request is sent down the
+// returned channel.
+func listenProxy(t *testing.T, auth string) (*url.URL, <-chan *http.Request) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	requests := make(chan *http.Request, 10)
+	go func() {
+		for {
+			client, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer client.Close()
+				req, err := http.ReadRequest(bufio.NewReader(client))
+				if err != nil {
+					return
+				}
+				requests <- req
+				if req.Method != http.MethodConnect {
+					io.WriteString(client, "HTTP/1.1 405 Method Not Allowed\r\n\r\n")
+					return
+				}
+				if auth != "" && req.Header.Get("Proxy-Authorization") != auth {
+					io.WriteString(client, "HTTP/1.1 407 Proxy Authentication Required\r\n\r\n")
+					return
+				}
+				backend, err := net.Dial("tcp", req.Host)
+				if err != nil {
+					io.WriteString(client, "HTTP/1.1 502 Bad Gateway\r\n\r\n")
+					return
+				}
+				defer backend.Close()
+				io.WriteString(client, "HTTP/1.1 200 Connection established\r\n\r\n")
+				go io.Copy(backend, client)
+				io.Copy(client, backend)
+			}()
+		}
+	}()
+	return &url.URL{Scheme: "http", Host: l.Addr().String()}, requests
+}
+
+func TestTCPWriterThroughHTTPProxy(t *testing.T) {
+	l, lines := listenTCP(t)
+	proxy, requests := listenProxy(t, "")
+
+	w, err := NewTCP(l.Addr().String(), WithHTTPProxy(proxy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	req := <-requests
+	if req.Method != http.MethodConnect || req.Host != l.Addr().String() {
+		t.Errorf("Expected CONNECT %s, got %s %s", l.Addr(), req.Method, req.Host)
+	}
+
+	if _, err := w.Log("tunneled"); err != nil {
+		t.Fatal(err)
+	}
+	if line := string(readLine(t, lines)); !strings.Contains(line, `"message":"tunneled"`) {
+		t.Errorf("Expected the message to reach the backend, got %s", line)
+	}
+}
+
+func TestTLSWriterThroughHTTPProxy(t *testing.T) {
+	cert, pool := selfSignedCert(t)
+	l, lines := listenTLS(t, cert)
+	proxy, requests := listenProxy(t, "")
+
+	w, err := NewTLS(l.Addr().String(), &tls.Config{RootCAs: pool}, WithHTTPProxy(proxy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if req := <-requests; req.Host != l.Addr().String() {
+		t.Errorf("Expected a tunnel to %s, got %s", l.Addr(), req.Host)
+	}
+
+	if _, err := w.Log("encrypted"); err != nil {
+		t.Fatal(err)
+	}
+	if line := string(readLine(t, lines)); !strings.Contains(line, `"message":"encrypted"`) {
+		t.Errorf("Expected the message to reach the backend, got %s", line)
+	}
+}
+
+func TestHTTPProxyAuthentication(t *testing.T) {
+	l, lines := listenTCP(t)
+	proxy, _ := listenProxy(t, "Basic dXNlcjpzZWNyZXQ=")
+
+	if _, err := NewTCP(l.Addr().String(), WithHTTPProxy(proxy)); err == nil || !strings.Contains(err.Error(), "407") {
+		t.Errorf("Expected the proxy to refuse an unauthenticated CONNECT, got %v", err)
+	}
+
+	proxy.User = url.UserPassword("user", "secret")
+	w, err := NewTCP(l.Addr().String(), WithHTTPProxy(proxy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	w.Log("authenticated")
+	readLine(t, lines)
+}
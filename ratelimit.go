@@ -0,0 +1,134 @@
+package logopher
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var _ FieldLogger = (*RateLimitedWriter)(nil)
+
+// RateLimitedWriter wraps a Writer and limits how many messages per second
+// reach it, so that a service stuck logging in a loop can't flood LogStash. It
+// is a token bucket: up to burst messages can be sent at once, after which
+// messages are let through at eventsPerSec.
+//
+// What happens to a message over the limit is decided by Policy. By default
+// the caller waits its turn. Under DropNewest or DropOldest the message is
+// discarded, as nothing is queued that could be dropped in its place.
+type RateLimitedWriter struct {
+	writer Writer
+	rate   float64
+	burst  float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+
+	dropped atomic.Uint64
+
+	// Policy decides what happens to a message over the limit
+	Policy OverflowPolicy
+}
+
+// NewRateLimitedWriter creates a RateLimitedWriter which lets eventsPerSec
+// messages a second through to w, with bursts of up to burst messages. A burst
+// of less than one is treated as one. An eventsPerSec of zero or less lets only
+// the first burst through, and drops everything after it, whatever the Policy.
+func NewRateLimitedWriter(w Writer, eventsPerSec int, burst int) *RateLimitedWriter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimitedWriter{
+		writer: w,
+		rate:   float64(eventsPerSec),
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Log writes msg if the limit allows. A dropped message reports 0 bytes
+// written, and no error.
+func (r *RateLimitedWriter) Log(msg string) (int, error) {
+	return r.LogFields(msg, nil)
+}
+
+// LogFields writes msg and fields if the limit allows. A dropped message
+// reports 0 bytes written, and no error.
+func (r *RateLimitedWriter) LogFields(msg string, fields map[string]interface{}) (int, error) {
+	if !r.take() {
+		return 0, nil
+	}
+	data, err := r.encode(msg, fields)
+	if err != nil {
+		return 0, unlessSampledOut(err)
+	}
+	return r.writer.Write(data)
+}
+
+// Write writes rawBytes if the limit allows. A dropped message reports 0 bytes
+// written, and no error.
+func (r *RateLimitedWriter) Write(rawBytes []byte) (int, error) {
+	if !r.take() {
+		return 0, nil
+	}
+	return r.writer.Write(rawBytes)
+}
+
+// encode builds payloads the same way the wrapped writer would
+func (r *RateLimitedWriter) encode(msg string, fields map[string]interface{}) ([]byte, error) {
+	return encodeFor(r.writer, msg, fields)
+}
+
+// take spends a token for one message, reporting whether it may be sent. Under
+// Block it always succeeds, waiting until the token it spent would have been
+// refilled.
+func (r *RateLimitedWriter) take() bool {
+	r.mu.Lock()
+	now := time.Now()
+	r.tokens += now.Sub(r.last).Seconds() * r.rate
+	if r.tokens > r.burst {
+		r.tokens = r.burst
+	}
+	r.last = now
+
+	if r.tokens >= 1 {
+		r.tokens--
+		r.mu.Unlock()
+		return true
+	}
+	if r.Policy != Block || r.rate <= 0 {
+		r.mu.Unlock()
+		r.dropped.Add(1)
+		return false
+	}
+	// Spend the token now, so that callers waiting together are spaced out
+	// rather than all waking at once
+	r.tokens--
+	wait := time.Duration(-r.tokens / r.rate * float64(time.Second))
+	r.mu.Unlock()
+	time.Sleep(wait)
+	return true
+}
+
+// DroppedCount returns how many messages have been discarded for being over
+// the limit
+func (r *RateLimitedWriter) DroppedCount() uint64 {
+	return r.dropped.Load()
+}
+
+// Flush flushes the wrapped writer, if it buffers messages
+func (r *RateLimitedWriter) Flush() error {
+	return flushWriter(r.writer)
+}
+
+// Reopen re-establishes the wrapped writer's connection
+func (r *RateLimitedWriter) Reopen() error {
+	return r.writer.Reopen()
+}
+
+// Close closes the wrapped writer
+func (r *RateLimitedWriter) Close() error {
+	return r.writer.Close()
+}
@@ -0,0 +1,67 @@
+package logopher
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimitedWriterDrops(t *testing.T) {
+	r := &recordingWriter{}
+	l := NewRateLimitedWriter(r, 10, 5)
+	l.Policy = DropNewest
+
+	for i := 0; i < 50; i++ {
+		if _, err := l.Log("storm"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Only the burst makes it through, give or take a token refilled while
+	// the loop ran
+	written := len(r.Payloads())
+	if written < 5 || written > 6 {
+		t.Errorf("Expected about 5 messages written, got %d", written)
+	}
+	if dropped := l.DroppedCount(); dropped != uint64(50-written) {
+		t.Errorf("Expected %d dropped, got %d", 50-written, dropped)
+	}
+}
+
+func TestRateLimitedWriterRefills(t *testing.T) {
+	r := &recordingWriter{}
+	l := NewRateLimitedWriter(r, 100, 1)
+	l.Policy = DropNewest
+
+	l.Log("first")
+	if n, _ := l.Log("too soon"); n != 0 {
+		t.Error("Expected the second message to be dropped")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if n, _ := l.Log("later"); n == 0 {
+		t.Error("Expected a message to be let through once the bucket refilled")
+	}
+}
+
+func TestRateLimitedWriterBlocks(t *testing.T) {
+	r := &recordingWriter{}
+	l := NewRateLimitedWriter(r, 100, 1)
+
+	start := time.Now()
+	for i := 0; i < 11; i++ {
+		if _, err := l.Log("steady"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	elapsed := time.Since(start)
+
+	if written := len(r.Payloads()); written != 11 {
+		t.Errorf("Expected all 11 messages written, got %d", written)
+	}
+	if l.DroppedCount() != 0 {
+		t.Errorf("Expected nothing dropped, got %d", l.DroppedCount())
+	}
+	// The first message spends the burst, and the other ten are let through
+	// at 100 a second
+	if elapsed < 90*time.Millisecond {
+		t.Errorf("Expected 10 messages at 100/s to take about 100ms, took %s", elapsed)
+	}
+}
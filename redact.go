@@ -0,0 +1,19 @@
+package logopher
+
+// redact applies the Redactor to msg and, if RedactFields is set, to the
+// string values in fields. Fields are copied rather than changed, as they
+// belong to the caller.
+func (u *baseWriter) redact(msg string, fields map[string]interface{}) (string, map[string]interface{}) {
+	msg = u.Redactor(msg)
+	if !u.RedactFields || len(fields) == 0 {
+		return msg, fields
+	}
+	redacted := make(map[string]interface{}, len(fields))
+	for k, v := range fields {
+		if s, ok := v.(string); ok {
+			v = u.Redactor(s)
+		}
+		redacted[k] = v
+	}
+	return msg, redacted
+}
@@ -0,0 +1,84 @@
+package logopher
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var emails = regexp.MustCompile(`[\w.+-]+@[\w-]+\.[\w.]+`)
+
+func maskEmails(s string) string {
+	return emails.ReplaceAllString(s, "[email]")
+}
+
+func TestRedactorMasksMessage(t *testing.T) {
+	conn := &fakeConn{}
+	w, _ := newFakeWriter(t, conn)
+	w.Redactor = maskEmails
+
+	fields := map[string]interface{}{"user": "bob@example.com"}
+	w.LogFields("signup from bob@example.com", fields)
+	event := lastEvent(t, conn)
+	if event["message"] != "signup from [email]" {
+		t.Errorf("Expected the message to be redacted, got %q", event["message"])
+	}
+	if event["user"] != "bob@example.com" {
+		t.Errorf("Expected fields to be left alone without RedactFields, got %q", event["user"])
+	}
+
+	w.Log("plain bob@example.com")
+	if event := lastEvent(t, conn); event["message"] != "plain [email]" {
+		t.Errorf("Expected a message without fields to be redacted, got %q", event["message"])
+	}
+}
+
+func TestRedactorMasksFields(t *testing.T) {
+	conn := &fakeConn{}
+	w, _ := newFakeWriter(t, conn)
+	w.Redactor = maskEmails
+	w.RedactFields = true
+	w.DefaultFields = map[string]interface{}{"owner": "ops@example.com"}
+
+	fields := map[string]interface{}{"user": "bob@example.com", "count": 3}
+	w.LogFields("hello", fields)
+	event := lastEvent(t, conn)
+	if event["user"] != "[email]" {
+		t.Errorf("Expected the user field to be redacted, got %q", event["user"])
+	}
+	if event["owner"] != "[email]" {
+		t.Errorf("Expected the default field to be redacted, got %q", event["owner"])
+	}
+	if event["count"] != float64(3) {
+		t.Errorf("Expected non-string fields to be untouched, got %v", event["count"])
+	}
+	if fields["user"] != "bob@example.com" {
+		t.Errorf("Expected the caller's fields to be unchanged, got %q", fields["user"])
+	}
+}
+
+func TestRedactorRunsBeforeTruncation(t *testing.T) {
+	l := listenUDP(t)
+	mask := func(s string) string { return strings.ReplaceAll(s, "secret-token-12345", "[token]") }
+	w, err := New(l.LocalAddr().String(), WithRedactor(mask, false), WithMaxMessageBytes(40))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	if _, err := w.Log("authenticating the request with secret-token-12345"); err != nil {
+		t.Fatal(err)
+	}
+	var event map[string]interface{}
+	if err := json.Unmarshal(readDatagram(t, l), &event); err != nil {
+		t.Fatal(err)
+	}
+	msg, _ := event["message"].(string)
+	if strings.Contains(msg, "secret") {
+		t.Errorf("Expected no part of the secret to be sent, got %q", msg)
+	}
+	if msg != "authenticating the request with [token]" {
+		t.Errorf("Expected the redacted message, got %q", msg)
+	}
+}
@@ -0,0 +1,34 @@
+package logopher
+
+import "context"
+
+// keepForReplay holds on to a message whose write failed, so it can be sent
+// again after the next reconnect. The oldest message is dropped to make room
+// once ReplayBufferSize are held. The caller must hold the mutex.
+func (u *baseWriter) keepForReplay(rawBytes []byte) {
+	if u.ReplayBufferSize <= 0 {
+		return
+	}
+	if len(u.replay) >= u.ReplayBufferSize {
+		u.replay = u.replay[len(u.replay)-u.ReplayBufferSize+1:]
+	}
+	u.replay = append(u.replay, append([]byte(nil), rawBytes...))
+}
+
+// replayFailed resends the messages kept by keepForReplay, oldest first, on the
+// freshly opened connection. It stops at the first failure, keeping that
+// message and the rest for next time, and returns the error. Since writeAll
+// closes the connection when a write fails, the reconnect has then failed too.
+// The caller must hold the mutex.
+func (u *baseWriter) replayFailed() error {
+	for len(u.replay) > 0 {
+		if _, err := u.writeAll(context.Background(), u.replay[0]); err != nil {
+			u.logf("Failed to replay %d messages to %s. Underlying error: %s", len(u.replay), u.address, err)
+			return err
+		}
+		u.stats.messagesWritten.Add(1)
+		u.replay = u.replay[1:]
+	}
+	u.replay = nil
+	return nil
+}
@@ -0,0 +1,107 @@
+package logopher
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestReplayAfterReopen(t *testing.T) {
+	writeErr := errors.New("broken pipe")
+	healthy := &fakeConn{}
+	w, _ := newFakeWriter(t, &fakeConn{failWrites: 1, writeErr: writeErr}, healthy)
+	w.ReplayBufferSize = 10
+
+	if _, err := w.Write([]byte("lost\n")); !errors.Is(err, writeErr) {
+		t.Fatalf("Expected %v, got %v", writeErr, err)
+	}
+	if err := w.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("next\n")); err != nil {
+		t.Fatal(err)
+	}
+	if writes := healthy.Writes(); !reflect.DeepEqual(writes, [][]byte{[]byte("lost\n"), []byte("next\n")}) {
+		t.Errorf("Expected the failed message replayed before the next, got %q", writes)
+	}
+}
+
+func TestReplayOnRetry(t *testing.T) {
+	healthy := &fakeConn{}
+	w, _ := newFakeWriter(t, &fakeConn{failWrites: 1, writeErr: errors.New("broken pipe")}, healthy)
+	w.ReplayBufferSize = 10
+
+	// The second write fails on the connection the first one broke, and the
+	// retry reconnects
+	w.Write([]byte("first\n"))
+	w.MaxRetries = 1
+	if _, err := w.Write([]byte("second\n")); err != nil {
+		t.Fatal(err)
+	}
+	if writes := healthy.Writes(); !reflect.DeepEqual(writes, [][]byte{[]byte("first\n"), []byte("second\n")}) {
+		t.Errorf("Expected the earlier failure replayed when the retry reconnected, got %q", writes)
+	}
+}
+
+func TestReplayBufferIsBounded(t *testing.T) {
+	// The first connection breaks, and every write until the Reopen fails on
+	// the closed connection
+	healthy := &fakeConn{}
+	w, _ := newFakeWriter(t, &fakeConn{failWrites: 1, writeErr: errors.New("broken pipe")}, healthy)
+	w.ReplayBufferSize = 2
+
+	for _, msg := range []string{"one\n", "two\n", "three\n"} {
+		w.Write([]byte(msg))
+	}
+	if err := w.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	if writes := healthy.Writes(); !reflect.DeepEqual(writes, [][]byte{[]byte("two\n"), []byte("three\n")}) {
+		t.Errorf("Expected only the 2 most recent failures replayed, got %q", writes)
+	}
+}
+
+func TestReplayDisabledByDefault(t *testing.T) {
+	healthy := &fakeConn{}
+	w, _ := newFakeWriter(t, &fakeConn{failWrites: 1, writeErr: errors.New("broken pipe")}, healthy)
+
+	w.Write([]byte("lost\n"))
+	if err := w.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	if writes := healthy.Writes(); len(writes) != 0 {
+		t.Errorf("Expected nothing replayed, got %q", writes)
+	}
+}
+
+func TestReplayFailureFailsReconnect(t *testing.T) {
+	writeErr := errors.New("broken pipe")
+	healthy := &fakeConn{}
+	reconnects := 0
+	w, _ := newFakeWriter(t,
+		&fakeConn{failWrites: 1, writeErr: writeErr},
+		&fakeConn{failWrites: 1, writeErr: writeErr},
+		healthy)
+	w.ReplayBufferSize = 10
+	w.OnReconnect = func(error) { reconnects++ }
+
+	w.Write([]byte("lost\n"))
+	// The replay breaks the connection Reopen just dialed
+	if err := w.Reopen(); !errors.Is(err, writeErr) {
+		t.Fatalf("Expected the replay's error from Reopen, got %v", err)
+	}
+	if w.IsOpen() {
+		t.Error("Expected the writer to report it has no connection")
+	}
+	if reconnects != 0 {
+		t.Errorf("Expected OnReconnect not to be called for a failed reconnect, got %d calls", reconnects)
+	}
+
+	// The message is still held, and sent once a reconnect succeeds
+	if err := w.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	if writes := healthy.Writes(); !reflect.DeepEqual(writes, [][]byte{[]byte("lost\n")}) {
+		t.Errorf("Expected the message replayed on the next connection, got %q", writes)
+	}
+}
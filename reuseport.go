@@ -0,0 +1,12 @@
+package logopher
+
+// WithReusePort sets SO_REUSEPORT on every socket before it is dialed, so that
+// several writers, such as those in a Pool, can be bound to the same local
+// port with WithLocalAddr. It is best-effort: where the option isn't
+// supported, or the system refuses it, the socket is dialed without it. It
+// replaces the Dialer.
+func WithReusePort() Option {
+	return func(u *baseWriter) {
+		u.netDialer().Control = reusePort
+	}
+}
@@ -0,0 +1,14 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+
+package logopher
+
+import "syscall"
+
+// reusePort sets SO_REUSEPORT on the socket about to be dialed, ignoring any
+// error, as WithReusePort is best-effort
+func reusePort(network, address string, c syscall.RawConn) error {
+	c.Control(func(fd uintptr) {
+		syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_REUSEPORT, 1)
+	})
+	return nil
+}
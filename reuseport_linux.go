@@ -0,0 +1,18 @@
+//go:build linux && (386 || amd64 || arm || arm64 || loong64 || ppc64 || ppc64le || riscv64 || s390x)
+
+package logopher
+
+import "syscall"
+
+// soReusePort is SO_REUSEPORT on these architectures, which the syscall
+// package doesn't define for linux
+const soReusePort = 0xf
+
+// reusePort sets SO_REUSEPORT on the socket about to be dialed, ignoring any
+// error, as WithReusePort is best-effort
+func reusePort(network, address string, c syscall.RawConn) error {
+	c.Control(func(fd uintptr) {
+		syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, soReusePort, 1)
+	})
+	return nil
+}
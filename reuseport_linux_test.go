@@ -0,0 +1,30 @@
+//go:build linux && (386 || amd64 || arm || arm64 || loong64 || ppc64 || ppc64le || riscv64 || s390x)
+
+package logopher
+
+import "testing"
+
+func TestWithReusePort(t *testing.T) {
+	server := listenUDP(t)
+	local := freeUDPPort(t)
+
+	first, err := New(server.LocalAddr().String(), WithLocalAddr(local), WithReusePort())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Close()
+	second, err := New(server.LocalAddr().String(), WithLocalAddr(local), WithReusePort())
+	if err != nil {
+		t.Fatalf("Expected a second writer to bind the same port, got %v", err)
+	}
+	defer second.Close()
+
+	if a, b := first.Conn().LocalAddr().String(), second.Conn().LocalAddr().String(); a != b {
+		t.Errorf("Expected both writers to be bound to %s, got %s and %s", local, a, b)
+	}
+
+	if w, err := New(server.LocalAddr().String(), WithLocalAddr(local)); err == nil {
+		w.Close()
+		t.Error("Expected binding the port without SO_REUSEPORT to fail")
+	}
+}
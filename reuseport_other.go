@@ -0,0 +1,10 @@
+//go:build !(linux && (386 || amd64 || arm || arm64 || loong64 || ppc64 || ppc64le || riscv64 || s390x)) && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+
+package logopher
+
+import "syscall"
+
+// reusePort does nothing, since SO_REUSEPORT isn't supported here
+func reusePort(network, address string, c syscall.RawConn) error {
+	return nil
+}
@@ -0,0 +1,57 @@
+package logopher
+
+import (
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+)
+
+// Sampler decides which messages are sent, so that only a fraction of a noisy
+// source reaches LogStash. Sample is called once per message, possibly from
+// several goroutines at once, and the message is sent only if it returns true.
+type Sampler interface {
+	Sample() bool
+}
+
+// EveryN returns a Sampler which lets through the first message of every n,
+// starting with the first. An n of one or less lets everything through.
+func EveryN(n int) Sampler {
+	if n < 1 {
+		n = 1
+	}
+	return &everyN{n: uint64(n)}
+}
+
+type everyN struct {
+	n    uint64
+	seen atomic.Uint64
+}
+
+func (s *everyN) Sample() bool {
+	return (s.seen.Add(1)-1)%s.n == 0
+}
+
+// Probability returns a Sampler which lets each message through with
+// probability p, from 0 for nothing to 1 for everything. Decisions are drawn
+// from r, so that a seeded source gives repeatable results, or from the
+// package's global source if r is nil.
+func Probability(p float64, r *rand.Rand) Sampler {
+	return &probability{p: p, r: r}
+}
+
+type probability struct {
+	p float64
+
+	// r is not safe for concurrent use, so draws from it are serialized
+	mu sync.Mutex
+	r  *rand.Rand
+}
+
+func (s *probability) Sample() bool {
+	if s.r == nil {
+		return rand.Float64() < s.p
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.r.Float64() < s.p
+}
@@ -0,0 +1,109 @@
+package logopher
+
+import (
+	"bytes"
+	"math/rand/v2"
+	"testing"
+	"time"
+)
+
+func TestEveryN(t *testing.T) {
+	s := EveryN(3)
+	var got []bool
+	for i := 0; i < 7; i++ {
+		got = append(got, s.Sample())
+	}
+	expected := []bool{true, false, false, true, false, false, true}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Fatalf("Expected %v, got %v", expected, got)
+		}
+	}
+
+	one := EveryN(0)
+	for i := 0; i < 3; i++ {
+		if !one.Sample() {
+			t.Error("Expected an n below one to let everything through")
+		}
+	}
+}
+
+func TestProbability(t *testing.T) {
+	s := Probability(0.25, rand.New(rand.NewPCG(1, 2)))
+	passed := 0
+	for i := 0; i < 10000; i++ {
+		if s.Sample() {
+			passed++
+		}
+	}
+	if passed < 2300 || passed > 2700 {
+		t.Errorf("Expected about 2500 of 10000 to pass, got %d", passed)
+	}
+
+	// The same seed makes the same decisions
+	a := Probability(0.5, rand.New(rand.NewPCG(7, 7)))
+	b := Probability(0.5, rand.New(rand.NewPCG(7, 7)))
+	for i := 0; i < 100; i++ {
+		if a.Sample() != b.Sample() {
+			t.Fatal("Expected identically seeded samplers to agree")
+		}
+	}
+}
+
+func TestSamplerDropsMessages(t *testing.T) {
+	conn := &fakeConn{}
+	w, _ := newFakeWriter(t, conn)
+	w.Sampler = EveryN(4)
+
+	for i := 0; i < 20; i++ {
+		n, err := w.Debug("chatty")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sampled := i%4 == 0; sampled != (n > 0) {
+			t.Errorf("Message %d: Expected sampled to be %v, wrote %d bytes", i, sampled, n)
+		}
+	}
+	if written := len(conn.Writes()); written != 5 {
+		t.Errorf("Expected 5 of 20 messages sent, got %d", written)
+	}
+
+	// Raw writes aren't messages the sampler knows about
+	w.Write([]byte("{}\n"))
+	if written := len(conn.Writes()); written != 6 {
+		t.Errorf("Expected Write to bypass the sampler, got %d writes", written)
+	}
+}
+
+func TestSamplerAppliesThroughWrappers(t *testing.T) {
+	tests := []struct {
+		name  string
+		wrap  func(w Writer) FieldLogger
+		flush func(l FieldLogger)
+	}{
+		{"AsyncWriter", func(w Writer) FieldLogger { return NewAsyncWriter(w, 100) }, func(l FieldLogger) { l.(*AsyncWriter).Flush() }},
+		{"BatchWriter", func(w Writer) FieldLogger { return NewBatchWriter(w, 100, 1<<20, time.Hour) }, func(l FieldLogger) { l.(*BatchWriter).Flush() }},
+		{"BreakerWriter", func(w Writer) FieldLogger { return NewBreakerWriter(w, 3, time.Minute) }, func(FieldLogger) {}},
+		{"RateLimitedWriter", func(w Writer) FieldLogger { return NewRateLimitedWriter(w, 1000, 100) }, func(FieldLogger) {}},
+	}
+	for _, test := range tests {
+		conn := &fakeConn{}
+		w, _ := newFakeWriter(t, conn)
+		w.Sampler = EveryN(10)
+		l := test.wrap(w)
+
+		for i := 0; i < 20; i++ {
+			n, err := l.LogFields("chatty", map[string]interface{}{"i": i})
+			if err != nil {
+				t.Fatalf("%s: %s", test.name, err)
+			}
+			if sampled := i%10 == 0; sampled != (n > 0) {
+				t.Errorf("%s: Message %d: Expected sampled to be %v, reported %d bytes", test.name, i, sampled, n)
+			}
+		}
+		test.flush(l)
+		if sent := bytes.Count(bytes.Join(conn.Writes(), nil), []byte("\n")); sent != 2 {
+			t.Errorf("%s: Expected 2 of 20 messages sent, got %d", test.name, sent)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package logopher
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"strings"
+)
+
+var _ slog.Handler = (*slogHandler)(nil)
+
+// slogHandler is a slog.Handler which sends each record through a FieldLogger
+type slogHandler struct {
+	writer       FieldLogger
+	opts         slog.HandlerOptions
+	levelStrings map[slog.Level]string
+	fields       map[string]interface{}
+	groups       []string
+}
+
+// SlogOptions configures the handler created by NewSlogHandlerWithOptions
+type SlogOptions struct {
+	slog.HandlerOptions
+	// LevelStrings overrides the level field sent for particular levels, such as
+	// to match the labels a dashboard expects, or to name custom levels. A
+	// level missing from it is sent as slog names it, in lowercase: debug,
+	// info, warn, error, or something like info+2 for a level in between.
+	LevelStrings map[slog.Level]string
+}
+
+// NewSlogHandler creates a slog.Handler which translates records into the
+// Logopher JSON envelope. The record's message becomes the message field, its
+// level becomes a lowercase level field, and attributes become fields, nested
+// under an object for each group. The envelope's @timestamp is used in place
+// of the record's own time. A nil opts uses the slog defaults.
+func NewSlogHandler(w FieldLogger, opts *slog.HandlerOptions) slog.Handler {
+	if opts == nil {
+		return NewSlogHandlerWithOptions(w, nil)
+	}
+	return NewSlogHandlerWithOptions(w, &SlogOptions{HandlerOptions: *opts})
+}
+
+// NewSlogHandlerWithOptions is NewSlogHandler, with the extra options in
+// SlogOptions. A nil opts uses the defaults.
+func NewSlogHandlerWithOptions(w FieldLogger, opts *SlogOptions) slog.Handler {
+	h := &slogHandler{
+		writer: w,
+		fields: make(map[string]interface{}),
+	}
+	if opts != nil {
+		h.opts = opts.HandlerOptions
+		h.levelStrings = opts.LevelStrings
+	}
+	return h
+}
+
+// Enabled reports whether records at level should be logged, according to the
+// Level in the handler options
+func (h *slogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	minLevel := slog.LevelInfo
+	if h.opts.Level != nil {
+		minLevel = h.opts.Level.Level()
+	}
+	return level >= minLevel
+}
+
+// Handle sends the record to LogStash
+func (h *slogHandler) Handle(_ context.Context, r slog.Record) error {
+	fields := cloneFields(h.fields)
+
+	attrs := make(map[string]interface{})
+	r.Attrs(func(a slog.Attr) bool {
+		h.addAttr(attrs, h.groups, a)
+		return true
+	})
+	if len(attrs) > 0 {
+		mergeFields(fields, h.groups, attrs)
+	}
+
+	h.addBuiltin(fields, slog.Any(slog.LevelKey, r.Level))
+	if h.opts.AddSource && r.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		h.addBuiltin(fields, slog.String(slog.SourceKey, fmt.Sprintf("%s:%d", frame.File, frame.Line)))
+	}
+
+	_, err := h.writer.LogFields(r.Message, fields)
+	return err
+}
+
+// WithAttrs returns a handler which includes attrs on every record, under the
+// current group
+func (h *slogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	resolved := make(map[string]interface{})
+	for _, a := range attrs {
+		h.addAttr(resolved, h.groups, a)
+	}
+	clone := *h
+	clone.fields = cloneFields(h.fields)
+	if len(resolved) > 0 {
+		mergeFields(clone.fields, h.groups, resolved)
+	}
+	return &clone
+}
+
+// WithGroup returns a handler which nests all subsequent attributes under name
+func (h *slogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	clone := *h
+	clone.groups = append(append([]string(nil), h.groups...), name)
+	return &clone
+}
+
+// addBuiltin adds one of the attributes slog itself supplies, such as the
+// level, to the top level of the event
+func (h *slogHandler) addBuiltin(fields map[string]interface{}, a slog.Attr) {
+	if h.opts.ReplaceAttr != nil {
+		a = h.opts.ReplaceAttr(nil, a)
+	}
+	if a.Key == "" {
+		return
+	}
+	if level, ok := a.Value.Any().(slog.Level); ok {
+		fields[a.Key] = h.levelString(level)
+		return
+	}
+	fields[a.Key] = slogValue(a.Value)
+}
+
+// levelString names level for the level field
+func (h *slogHandler) levelString(level slog.Level) string {
+	if name, ok := h.levelStrings[level]; ok {
+		return name
+	}
+	return strings.ToLower(level.String())
+}
+
+// addAttr resolves a into fields, following the slog rules: empty attributes
+// are dropped, empty groups are dropped, and groups without a key are inlined
+func (h *slogHandler) addAttr(fields map[string]interface{}, groups []string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Value.Kind() != slog.KindGroup && h.opts.ReplaceAttr != nil {
+		a = h.opts.ReplaceAttr(groups, a)
+		a.Value = a.Value.Resolve()
+	}
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+
+	if a.Value.Kind() != slog.KindGroup {
+		if a.Key != "" {
+			fields[a.Key] = slogValue(a.Value)
+		}
+		return
+	}
+
+	members := a.Value.Group()
+	if len(members) == 0 {
+		return
+	}
+	target := fields
+	if a.Key != "" {
+		nested, ok := fields[a.Key].(map[string]interface{})
+		if !ok {
+			nested = make(map[string]interface{})
+		}
+		target = nested
+		groups = append(groups[:len(groups):len(groups)], a.Key)
+	}
+	for _, member := range members {
+		h.addAttr(target, groups, member)
+	}
+	if a.Key != "" && len(target) > 0 {
+		fields[a.Key] = target
+	}
+}
+
+// slogValue converts a resolved, non-group slog.Value into something
+// encoding/json will render the same way slog's own JSONHandler does
+func slogValue(v slog.Value) interface{} {
+	switch v.Kind() {
+	case slog.KindString:
+		return v.String()
+	case slog.KindInt64:
+		return v.Int64()
+	case slog.KindUint64:
+		return v.Uint64()
+	case slog.KindFloat64:
+		return v.Float64()
+	case slog.KindBool:
+		return v.Bool()
+	case slog.KindDuration:
+		return int64(v.Duration())
+	case slog.KindTime:
+		return v.Time()
+	}
+	if err, ok := v.Any().(error); ok {
+		return err.Error()
+	}
+	return v.Any()
+}
+
+// cloneFields deep copies fields, including any nested groups, so a derived
+// handler never modifies the fields of the handler it came from
+func cloneFields(fields map[string]interface{}) map[string]interface{} {
+	clone := make(map[string]interface{}, len(fields))
+	for k, v := range fields {
+		if nested, ok := v.(map[string]interface{}); ok {
+			v = cloneFields(nested)
+		}
+		clone[k] = v
+	}
+	return clone
+}
+
+// mergeFields copies attrs into fields, nested under the given group path
+func mergeFields(fields map[string]interface{}, groups []string, attrs map[string]interface{}) {
+	for _, g := range groups {
+		nested, ok := fields[g].(map[string]interface{})
+		if !ok {
+			nested = make(map[string]interface{})
+			fields[g] = nested
+		}
+		fields = nested
+	}
+	for k, v := range attrs {
+		if existing, ok := fields[k].(map[string]interface{}); ok {
+			if incoming, ok := v.(map[string]interface{}); ok {
+				mergeFields(existing, nil, incoming)
+				continue
+			}
+		}
+		fields[k] = v
+	}
+}
@@ -0,0 +1,213 @@
+package logopher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newSlogLogger builds an slog.Logger whose output is captured by a fakeConn
+func newSlogLogger(t *testing.T, opts *slog.HandlerOptions) (*slog.Logger, *fakeConn) {
+	conn := &fakeConn{}
+	w, _ := newFakeWriter(t, conn)
+	return slog.New(NewSlogHandler(w, opts)), conn
+}
+
+// lastEvent parses the most recent payload written to conn
+func lastEvent(t *testing.T, conn *fakeConn) map[string]interface{} {
+	writes := conn.Writes()
+	if len(writes) == 0 {
+		t.Fatal("Nothing was written")
+	}
+	var event map[string]interface{}
+	if err := json.Unmarshal(writes[len(writes)-1], &event); err != nil {
+		t.Fatal(err)
+	}
+	return event
+}
+
+func TestSlogHandlerRecord(t *testing.T) {
+	logger, conn := newSlogLogger(t, nil)
+
+	logger.Warn("disk almost full",
+		"percent", 93,
+		"mount", "/var",
+		"critical", false,
+		"elapsed", 2*time.Second,
+		"err", errors.New("ENOSPC"))
+
+	event := lastEvent(t, conn)
+	expected := map[string]interface{}{
+		"message":  "disk almost full",
+		"level":    "warn",
+		"percent":  float64(93),
+		"mount":    "/var",
+		"critical": false,
+		"elapsed":  float64(2 * time.Second),
+		"err":      "ENOSPC",
+	}
+	for k, v := range expected {
+		if !reflect.DeepEqual(event[k], v) {
+			t.Errorf("Expected %s to be %#v, got %#v", k, v, event[k])
+		}
+	}
+}
+
+func TestSlogHandlerLevel(t *testing.T) {
+	logger, conn := newSlogLogger(t, &slog.HandlerOptions{Level: slog.LevelWarn})
+
+	logger.Info("dropped")
+	if len(conn.Writes()) != 0 {
+		t.Error("Expected the info record to be dropped")
+	}
+	logger.Error("kept")
+	if event := lastEvent(t, conn); event["level"] != "error" {
+		t.Errorf("Expected level error, got %v", event["level"])
+	}
+}
+
+func TestSlogHandlerWithAttrsAndGroups(t *testing.T) {
+	logger, conn := newSlogLogger(t, nil)
+
+	base := logger.With("service", "auth")
+	req := base.WithGroup("request").With("id", "abc123")
+	req.Info("handled", "status", 200)
+
+	event := lastEvent(t, conn)
+	if event["service"] != "auth" {
+		t.Errorf("Expected service auth, got %v", event["service"])
+	}
+	expected := map[string]interface{}{"id": "abc123", "status": float64(200)}
+	if !reflect.DeepEqual(event["request"], expected) {
+		t.Errorf("Expected request to be %v, got %v", expected, event["request"])
+	}
+
+	// The derived loggers must not have leaked attributes into their parent
+	base.Info("plain")
+	if event := lastEvent(t, conn); event["request"] != nil {
+		t.Errorf("Expected no request group on the parent logger, got %v", event["request"])
+	}
+}
+
+func TestSlogHandlerAddSource(t *testing.T) {
+	logger, conn := newSlogLogger(t, &slog.HandlerOptions{AddSource: true})
+
+	logger.Info("where am i")
+	source, _ := lastEvent(t, conn)["source"].(string)
+	if !strings.Contains(source, "slog_test.go:") {
+		t.Errorf("Expected source to point at this file, got %q", source)
+	}
+}
+
+func TestSlogHandlerWithWriterAddSource(t *testing.T) {
+	for _, handlerSource := range []bool{true, false} {
+		conn := &fakeConn{}
+		w, _ := newFakeWriter(t, conn)
+		w.AddSource = true
+		logger := slog.New(NewSlogHandler(w, &slog.HandlerOptions{AddSource: handlerSource}))
+
+		logger.Info("where am i")
+		source, _ := lastEvent(t, conn)[SourceField].(string)
+		if !strings.Contains(source, "slog_test.go:") {
+			t.Errorf("handler AddSource %t: Expected source to point at this file, got %q", handlerSource, source)
+		}
+	}
+}
+
+func TestSlogHandlerReplaceAttr(t *testing.T) {
+	logger, conn := newSlogLogger(t, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == "password" {
+				return slog.String("password", "[redacted]")
+			}
+			return a
+		},
+	})
+
+	logger.Info("login", "user", "bob", "password", "hunter2")
+	if event := lastEvent(t, conn); event["password"] != "[redacted]" {
+		t.Errorf("Expected the password to be replaced, got %v", event["password"])
+	}
+}
+
+func TestSlogHandlerLevelStrings(t *testing.T) {
+	const levelTrace = slog.Level(-8)
+	tests := []struct {
+		opts     *SlogOptions
+		level    slog.Level
+		expected string
+	}{
+		{&SlogOptions{HandlerOptions: slog.HandlerOptions{Level: slog.LevelDebug}}, slog.LevelDebug, "debug"},
+		{nil, slog.LevelInfo, "info"},
+		{nil, slog.LevelWarn, "warn"},
+		{nil, slog.LevelError, "error"},
+		{nil, slog.LevelInfo + 2, "info+2"},
+		{&SlogOptions{LevelStrings: map[slog.Level]string{slog.LevelWarn: "WARNING"}}, slog.LevelWarn, "WARNING"},
+		{&SlogOptions{LevelStrings: map[slog.Level]string{slog.LevelWarn: "WARNING"}}, slog.LevelError, "error"},
+		{
+			&SlogOptions{
+				HandlerOptions: slog.HandlerOptions{Level: levelTrace},
+				LevelStrings:   map[slog.Level]string{levelTrace: "trace"},
+			},
+			levelTrace, "trace",
+		},
+	}
+	for _, test := range tests {
+		conn := &fakeConn{}
+		w, _ := newFakeWriter(t, conn)
+		logger := slog.New(NewSlogHandlerWithOptions(w, test.opts))
+		logger.Log(context.Background(), test.level, "leveled")
+		if level := lastEvent(t, conn)["level"]; level != test.expected {
+			t.Errorf("%s: Expected level %q, got %v", test.level, test.expected, level)
+		}
+	}
+}
+
+func TestSlogHandlerNestedGroups(t *testing.T) {
+	logger, conn := newSlogLogger(t, nil)
+
+	logger.WithGroup("http").Info("one level", "method", "GET", "status", 200)
+	event := lastEvent(t, conn)
+	expected := map[string]interface{}{"method": "GET", "status": float64(200)}
+	if !reflect.DeepEqual(event["http"], expected) {
+		t.Errorf("Expected http to be %v, got %v", expected, event["http"])
+	}
+	if _, ok := event["method"]; ok {
+		t.Error("Expected the grouped attributes not to be flattened")
+	}
+
+	http := logger.WithGroup("http").With("method", "POST")
+	http.WithGroup("request").Info("two levels",
+		"path", "/login",
+		slog.Group("headers", "accept", "json"))
+	event = lastEvent(t, conn)
+	expected = map[string]interface{}{
+		"method": "POST",
+		"request": map[string]interface{}{
+			"path":    "/login",
+			"headers": map[string]interface{}{"accept": "json"},
+		},
+	}
+	if !reflect.DeepEqual(event["http"], expected) {
+		t.Errorf("Expected http to be %v, got %v", expected, event["http"])
+	}
+	if event["level"] != "info" || event["message"] != "two levels" {
+		t.Errorf("Expected the built in fields to stay at the top level, got %v", event)
+	}
+
+	// A group with nothing in it is left out, as slog requires
+	logger.WithGroup("http").WithGroup("request").Info("empty")
+	if _, ok := lastEvent(t, conn)["http"]; ok {
+		t.Error("Expected an empty group to be left out")
+	}
+	http.WithGroup("request").Info("empty inner group")
+	expected = map[string]interface{}{"method": "POST"}
+	if event := lastEvent(t, conn); !reflect.DeepEqual(event["http"], expected) {
+		t.Errorf("Expected http to be %v, got %v", expected, event["http"])
+	}
+}
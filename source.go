@@ -0,0 +1,58 @@
+package logopher
+
+import (
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// SourceField is the field the call site is sent in when AddSource is enabled
+const SourceField = "source"
+
+// packagePath is the import path of this package, which begins the name of
+// every function in it and in its subpackages, such as logopherhook
+var packagePath = reflect.TypeOf(baseWriter{}).PkgPath()
+
+// inPackage reports whether function belongs to this package or one of its
+// subpackages
+func inPackage(function string) bool {
+	return strings.HasPrefix(function, packagePath+".") || strings.HasPrefix(function, packagePath+"/")
+}
+
+// adapterPrefixes begin the names of functions in the logging packages this
+// package's adapters sit behind, whose frames are between the adapter and the
+// code which logged
+var adapterPrefixes = []string{"log/slog.", "log.", "github.com/sirupsen/logrus."}
+
+// inAdapter reports whether function belongs to one of the logging packages
+// named by adapterPrefixes
+func inAdapter(function string) bool {
+	for _, prefix := range adapterPrefixes {
+		if strings.HasPrefix(function, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// callerSource returns the file:line of the first caller outside this package,
+// its subpackages, and the logging packages its adapters sit behind, so that
+// the source points at the code which logged, however many of this package's
+// writers and helpers the message passed through on the way
+func callerSource() string {
+	var pcs [32]uintptr
+	n := runtime.Callers(2, pcs[:])
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		// This package's own tests log from inside the package, so they count
+		// as callers
+		if strings.HasSuffix(frame.File, "_test.go") || !inPackage(frame.Function) && !inAdapter(frame.Function) {
+			return frame.File + ":" + strconv.Itoa(frame.Line)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
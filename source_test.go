@@ -0,0 +1,93 @@
+package logopher
+
+import (
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// line returns the line it was called from
+func line() int {
+	_, _, n, _ := runtime.Caller(1)
+	return n
+}
+
+func TestAddSource(t *testing.T) {
+	conn := &fakeConn{}
+	w, _ := newFakeWriter(t, conn)
+	w.AddSource = true
+
+	tests := []struct {
+		name string
+		log  func() int
+	}{
+		{"Log", func() int { w.Log("here"); return line() }},
+		{"LogFields", func() int { w.LogFields("here", map[string]interface{}{"k": "v"}); return line() }},
+		{"Info", func() int { w.Info("here"); return line() }},
+		{"AsyncWriter", func() int {
+			a := NewAsyncWriter(w, 1)
+			a.Log("here")
+			n := line() - 1
+			a.Flush()
+			return n
+		}},
+	}
+	for _, test := range tests {
+		expected := test.log()
+		source, _ := lastEvent(t, conn)[SourceField].(string)
+		file, n, _ := strings.Cut(source, ":")
+		if filepath.Base(file) != "source_test.go" {
+			t.Errorf("%s: Expected the source to be in source_test.go, got %q", test.name, source)
+		}
+		if n != strconv.Itoa(expected) {
+			t.Errorf("%s: Expected line %d, got %q", test.name, expected, source)
+		}
+	}
+}
+
+func TestAddSourceDisabledByDefault(t *testing.T) {
+	conn := &fakeConn{}
+	w, _ := newFakeWriter(t, conn)
+	w.Log("here")
+	if _, ok := lastEvent(t, conn)[SourceField]; ok {
+		t.Error("Expected no source field by default")
+	}
+}
+
+func TestInPackage(t *testing.T) {
+	tests := []struct {
+		function string
+		expected bool
+	}{
+		{packagePath + ".(*baseWriter).Write", true},
+		{packagePath + "/logopherhook.(*Hook).Fire", true},
+		{packagePath + "/logophertest.(*MemoryWriter).LogFields", true},
+		{packagePath + "Extras.Log", false},
+		{"main.main", false},
+	}
+	for _, test := range tests {
+		if got := inPackage(test.function); got != test.expected {
+			t.Errorf("%s: Expected %t, got %t", test.function, test.expected, got)
+		}
+	}
+}
+
+func TestInAdapter(t *testing.T) {
+	tests := []struct {
+		function string
+		expected bool
+	}{
+		{"log/slog.(*Logger).log", true},
+		{"log.(*Logger).Output", true},
+		{"github.com/sirupsen/logrus.(*Entry).fireHooks", true},
+		{"logging.Info", false},
+		{"main.main", false},
+	}
+	for _, test := range tests {
+		if got := inAdapter(test.function); got != test.expected {
+			t.Errorf("%s: Expected %t, got %t", test.function, test.expected, got)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package logopher
+
+import "sync/atomic"
+
+// Stats is a snapshot of a writer's delivery counters
+type Stats struct {
+	// MessagesWritten is how many calls to Write, or to anything which logs,
+	// delivered their whole payload
+	MessagesWritten uint64
+	// BytesWritten is how many bytes were written to the connection, including
+	// those from writes which later failed
+	BytesWritten uint64
+	// WriteErrors is how many calls to Write, or to anything which logs, failed
+	// after exhausting any retries
+	WriteErrors uint64
+	// Reconnects is how many times the connection was successfully re-dialed,
+	// whether by Reopen or by a retry
+	Reconnects uint64
+}
+
+// counters holds the live values behind Stats. They are updated atomically, so
+// they can be read without waiting on a write in progress.
+type counters struct {
+	messagesWritten atomic.Uint64
+	bytesWritten    atomic.Uint64
+	writeErrors     atomic.Uint64
+	reconnects      atomic.Uint64
+}
+
+// Stats returns a snapshot of the writer's delivery counters. It is safe to
+// call while other goroutines are writing.
+func (u *baseWriter) Stats() Stats {
+	return Stats{
+		MessagesWritten: u.stats.messagesWritten.Load(),
+		BytesWritten:    u.stats.bytesWritten.Load(),
+		WriteErrors:     u.stats.writeErrors.Load(),
+		Reconnects:      u.stats.reconnects.Load(),
+	}
+}
@@ -0,0 +1,41 @@
+package logopher
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	writeErr := errors.New("connection refused")
+	first := &fakeConn{}
+	second := &fakeConn{}
+	w, _ := newFakeWriter(t, first, second)
+
+	w.Write([]byte("one\n"))
+	w.Write([]byte("two\n"))
+	first.failWrites, first.writeErr = 1, writeErr
+	w.Write([]byte("lost\n"))
+	if err := w.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("three\n"))
+
+	expected := Stats{MessagesWritten: 3, BytesWritten: 14, WriteErrors: 1, Reconnects: 1}
+	if stats := w.Stats(); stats != expected {
+		t.Errorf("Expected %+v, got %+v", expected, stats)
+	}
+}
+
+func TestStatsCountRetries(t *testing.T) {
+	writeErr := errors.New("connection refused")
+	w, _ := newFakeWriter(t, &fakeConn{failWrites: 1, writeErr: writeErr}, &fakeConn{})
+	w.MaxRetries = 1
+
+	if _, err := w.Write([]byte("retried\n")); err != nil {
+		t.Fatal(err)
+	}
+	expected := Stats{MessagesWritten: 1, BytesWritten: 8, Reconnects: 1}
+	if stats := w.Stats(); stats != expected {
+		t.Errorf("Expected %+v, got %+v", expected, stats)
+	}
+}
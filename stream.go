@@ -0,0 +1,73 @@
+package logopher
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+)
+
+// StreamFrom sends every line read from r as an event, such as to replay a
+// file of newline delimited JSON, and returns how many were sent. Each line
+// must be a complete JSON object, and is sent as LogEvent would send it. Blank
+// lines are skipped. Streaming stops at the first line which can't be sent,
+// returning how many were sent before it along with the error.
+func (u *baseWriter) StreamFrom(r io.Reader) (int64, error) {
+	return u.StreamFromContext(context.Background(), r)
+}
+
+// StreamFromContext is StreamFrom, but stops once ctx is done, and bounds each
+// write by ctx as WriteContext does
+func (u *baseWriter) StreamFromContext(ctx context.Context, r io.Reader) (int64, error) {
+	br := bufio.NewReader(r)
+	var sent int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return sent, err
+		}
+		line, readErr := br.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			data, err := u.eventPayload(line)
+			if err != nil {
+				return sent, err
+			}
+			if _, err := u.WriteContext(ctx, data); err != nil {
+				return sent, err
+			}
+			sent++
+		}
+		if readErr == io.EOF {
+			return sent, nil
+		}
+		if readErr != nil {
+			return sent, readErr
+		}
+	}
+}
+
+// Pipe logs every message received from ch, as Log would, until ch is closed or
+// done is, such as to feed the writer from a fan-in of producers. It returns
+// nil once either is closed, leaving anything still in ch unread, or the first
+// error from logging a message, which stops it. Whether a write survives a
+// broken connection is up to MaxRetries and AutoReconnect, as for Log.
+func (u *baseWriter) Pipe(ch <-chan string, done <-chan struct{}) error {
+	for {
+		// Checked first, so a busy channel can't keep Pipe from noticing
+		select {
+		case <-done:
+			return nil
+		default:
+		}
+		select {
+		case <-done:
+			return nil
+		case msg, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			if _, err := u.Log(msg); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package logopher
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStreamFrom(t *testing.T) {
+	conn := &fakeConn{}
+	w, _ := newFakeWriter(t, conn)
+
+	input := "{\"message\": \"one\"}\n{\"message\": \"two\", \"n\": 2}\n\n  {\"message\": \"three\"}"
+	sent, err := w.StreamFrom(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sent != 3 {
+		t.Errorf("Expected 3 events sent, got %d", sent)
+	}
+	writes := conn.Writes()
+	expected := []string{
+		"{\"message\":\"one\"}\n",
+		"{\"message\":\"two\",\"n\":2}\n",
+		"{\"message\":\"three\"}\n",
+	}
+	if len(writes) != len(expected) {
+		t.Fatalf("Expected %d writes, got %d", len(expected), len(writes))
+	}
+	for i := range expected {
+		if string(writes[i]) != expected[i] {
+			t.Errorf("Expected write %d to be %q, got %q", i, expected[i], writes[i])
+		}
+	}
+}
+
+func TestStreamFromStopsAtBadLine(t *testing.T) {
+	conn := &fakeConn{}
+	w, _ := newFakeWriter(t, conn)
+
+	// An object split over two lines isn't a line of JSON
+	input := "{\"message\": \"one\"}\n{\"message\": \"two\"}\n{\n\"message\": \"three\"}\n{\"message\": \"four\"}\n"
+	sent, err := w.StreamFrom(strings.NewReader(input))
+	if err == nil {
+		t.Error("Expected an error for a line which isn't a JSON object")
+	}
+	if sent != 2 || len(conn.Writes()) != 2 {
+		t.Errorf("Expected 2 events sent before the bad line, got %d", sent)
+	}
+}
+
+func TestStreamFromContextCanceled(t *testing.T) {
+	conn := &fakeConn{}
+	w, _ := newFakeWriter(t, conn)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	sent, err := w.StreamFromContext(ctx, strings.NewReader("{\"message\": \"one\"}\n"))
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if sent != 0 || len(conn.Writes()) != 0 {
+		t.Errorf("Expected nothing sent, got %d", sent)
+	}
+}
+
+func TestPipe(t *testing.T) {
+	conn := &fakeConn{}
+	w, _ := newFakeWriter(t, conn)
+
+	ch := make(chan string)
+	piped := make(chan error, 1)
+	go func() { piped <- w.Pipe(ch, nil) }()
+	expected := []string{"one", "two", "three"}
+	for _, msg := range expected {
+		ch <- msg
+	}
+	close(ch)
+	select {
+	case err := <-piped:
+		if err != nil {
+			t.Fatalf("Expected no error once the channel closed, got %s", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected Pipe to return once the channel closed")
+	}
+
+	writes := conn.Writes()
+	if len(writes) != len(expected) {
+		t.Fatalf("Expected %d messages delivered, got %d", len(expected), len(writes))
+	}
+	for i, msg := range expected {
+		if !strings.Contains(string(writes[i]), `"message":"`+msg+`"`) {
+			t.Errorf("Expected message %d to be %s, got %s", i, msg, writes[i])
+		}
+	}
+}
+
+func TestPipeStopsOnDone(t *testing.T) {
+	w, _ := newFakeWriter(t, &fakeConn{})
+	ch := make(chan string)
+	done := make(chan struct{})
+	piped := make(chan error, 1)
+	go func() { piped <- w.Pipe(ch, done) }()
+
+	ch <- "one"
+	close(done)
+	select {
+	case err := <-piped:
+		if err != nil {
+			t.Fatalf("Expected no error once done, got %s", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected Pipe to return once done was closed")
+	}
+}
+
+func TestPipeReturnsFirstError(t *testing.T) {
+	broken := errors.New("broken pipe")
+	w, _ := newFakeWriter(t, &fakeConn{failWrites: 1, writeErr: broken})
+	ch := make(chan string, 2)
+	ch <- "doomed"
+	ch <- "never read"
+
+	if err := w.Pipe(ch, nil); !errors.Is(err, broken) {
+		t.Fatalf("Expected the write error, got %v", err)
+	}
+	if len(ch) != 1 {
+		t.Errorf("Expected Pipe to stop at the first error, leaving 1 message, got %d", len(ch))
+	}
+}
@@ -0,0 +1,49 @@
+package logopher
+
+import "io"
+
+// WriteSyncer matches zapcore.WriteSyncer, which lets any Logopher writer be
+// used as the destination of a zap core without this package depending on zap.
+// Entries encoded by zap are complete JSON objects ending in a newline, so they
+// are sent through Write as-is, for example:
+//
+//	w, err := logopher.DialUDP(address, false)
+//	...
+//	config := zap.NewProductionEncoderConfig()
+//	config.TimeKey = "@timestamp"
+//	config.MessageKey = "message"
+//	config.EncodeTime = zapcore.RFC3339NanoTimeEncoder
+//	core := zapcore.NewCore(zapcore.NewJSONEncoder(config), w, zap.InfoLevel)
+//	logger := zap.New(core)
+//
+// The logopherzap package builds the same core with logopherzap.NewCore.
+type WriteSyncer interface {
+	io.Writer
+	Sync() error
+}
+
+var (
+	_ WriteSyncer = (*UDPWriter)(nil)
+	_ WriteSyncer = (*TCPWriter)(nil)
+	_ WriteSyncer = (*TLSWriter)(nil)
+	_ WriteSyncer = (*UnixWriter)(nil)
+	_ WriteSyncer = (*BeatsWriter)(nil)
+	_ WriteSyncer = (*BatchWriter)(nil)
+)
+
+// Sync does nothing, since every Write is sent immediately. It exists so the
+// writer satisfies zapcore.WriteSyncer.
+func (u *baseWriter) Sync() error {
+	return nil
+}
+
+// Sync does nothing, since every Write waits for its acknowledgement
+func (b *BeatsWriter) Sync() error {
+	return nil
+}
+
+// Sync writes the current batch, so that zap's Sync, which is usually called
+// before exiting, doesn't leave messages behind
+func (b *BatchWriter) Sync() error {
+	return b.Flush()
+}
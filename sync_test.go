@@ -0,0 +1,40 @@
+package logopher
+
+import "testing"
+
+func TestWriteSyncerPassesEntriesThrough(t *testing.T) {
+	conn := &fakeConn{}
+	w, _ := newFakeWriter(t, conn)
+
+	// What a zap JSON core hands to its WriteSyncer for each entry
+	var ws WriteSyncer = w
+	entry := `{"level":"info","@timestamp":"2026-10-14T15:00:00Z","message":"from zap","user":"bob"}` + "\n"
+	if _, err := ws.Write([]byte(entry)); err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if writes := conn.Writes(); len(writes) != 1 || string(writes[0]) != entry {
+		t.Errorf("Expected the entry to be sent as-is, got %q", writes)
+	}
+}
+
+func TestBatchWriterSync(t *testing.T) {
+	r := &recordingWriter{}
+	b := NewBatchWriter(r, 100, 1<<16, 0)
+	defer b.Close()
+
+	var ws WriteSyncer = b
+	ws.Write([]byte("{}\n"))
+	ws.Write([]byte("{}\n"))
+	if len(r.Payloads()) != 0 {
+		t.Fatal("Expected the entries to be batched")
+	}
+	if err := ws.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if payloads := r.Payloads(); len(payloads) != 1 || string(payloads[0]) != "{}\n{}\n" {
+		t.Errorf("Expected Sync to flush the batch, got %q", payloads)
+	}
+}
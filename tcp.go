@@ -0,0 +1,35 @@
+package logopher
+
+var _ FieldLogger = (*TCPWriter)(nil)
+
+// TCPWriter represents an abstraction over the raw TCPConn and error handling
+// for writing data to logstash via tcp. Because tcp is stream oriented, Write
+// will keep writing until the whole message has been delivered.
+type TCPWriter struct {
+	baseWriter
+}
+
+// DialTCP creates a new TCPWriter
+func DialTCP(address string, enableLogging bool) (*TCPWriter, error) {
+	return NewTCP(address, WithLogging(enableLogging))
+}
+
+// NewTCP creates a TCPWriter for address, configured by opts the same way New
+// configures a UDPWriter
+func NewTCP(address string, opts ...Option) (*TCPWriter, error) {
+	address, err := normalizeAddress(address)
+	if err != nil {
+		return nil, err
+	}
+	writer := &TCPWriter{
+		baseWriter: newBaseWriter("tcp", address, false),
+	}
+	for _, opt := range opts {
+		opt(&writer.baseWriter)
+	}
+
+	if err := writer.open(); err != nil {
+		return nil, err
+	}
+	return writer, nil
+}
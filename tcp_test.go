@@ -0,0 +1,198 @@
+package logopher
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// listenTCP opens a local TCP listener to stand in for LogStash. Every line
+// received on any accepted connection is sent down the returned channel.
+func listenTCP(t *testing.T) (net.Listener, <-chan []byte) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	lines := make(chan []byte, 100)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				for {
+					line, err := r.ReadBytes('\n')
+					if err != nil {
+						return
+					}
+					lines <- line
+				}
+			}()
+		}
+	}()
+	return l, lines
+}
+
+// readLine waits for a single line to arrive at the listener
+func readLine(t *testing.T, lines <-chan []byte) []byte {
+	select {
+	case line := <-lines:
+		return line
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for a line")
+	}
+	return nil
+}
+
+func TestTCPWrite(t *testing.T) {
+	l, lines := listenTCP(t)
+	w, err := DialTCP(l.Addr().String(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	payload := append(bytes.Repeat([]byte("x"), 1<<20), '\n')
+	n, err := w.Write(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(payload) {
+		t.Errorf("Expected to write %d bytes, wrote %d", len(payload), n)
+	}
+	if got := readLine(t, lines); !bytes.Equal(got, payload) {
+		t.Errorf("Received %d bytes that did not match the %d sent", len(got), len(payload))
+	}
+}
+
+func TestTCPLogAndReopen(t *testing.T) {
+	l, lines := listenTCP(t)
+	w, err := DialTCP(l.Addr().String(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	for i, msg := range []string{"before reopen", "after reopen"} {
+		if i == 1 {
+			if err := w.Reopen(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := w.Log(msg); err != nil {
+			t.Fatal(err)
+		}
+		var event map[string]string
+		if err := json.Unmarshal(readLine(t, lines), &event); err != nil {
+			t.Fatal(err)
+		}
+		if event["message"] != msg {
+			t.Errorf("Expected message %q, got %q", msg, event["message"])
+		}
+	}
+}
+
+func TestTCPMultilineMessageIsOneLine(t *testing.T) {
+	l, lines := listenTCP(t)
+	w, err := DialTCP(l.Addr().String(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	trace := "panic: runtime error: index out of range\n\ngoroutine 1 [running]:\nmain.main()\n\t/app/main.go:12 +0x1d\n"
+	if _, err := w.LogFields(trace, map[string]interface{}{"stack": trace}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Log("after"); err != nil {
+		t.Fatal(err)
+	}
+
+	var event map[string]interface{}
+	if err := json.Unmarshal(readLine(t, lines), &event); err != nil {
+		t.Fatalf("Expected the whole trace on the first line: %s", err)
+	}
+	if event["message"] != trace || event["stack"] != trace {
+		t.Errorf("Expected the trace intact, got %q", event["message"])
+	}
+	if err := json.Unmarshal(readLine(t, lines), &event); err != nil {
+		t.Fatal(err)
+	}
+	if event["message"] != "after" {
+		t.Errorf("Expected the next message on the second line, got %q", event["message"])
+	}
+}
+
+func TestTCPWriterConcurrentReopen(t *testing.T) {
+	l, lines := listenTCP(t)
+	w, err := DialTCP(l.Addr().String(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	w.MaxRetries = 1
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if err := w.Reopen(); err != nil {
+				t.Errorf("Expected Reopen to succeed, got %v", err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			w.Log("during reopens")
+		}()
+	}
+	wg.Wait()
+
+	if !w.IsOpen() {
+		t.Fatal("Expected the writer to be connected after the reopens")
+	}
+	if _, err := w.Log("after reopens"); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.After(2 * time.Second)
+	for {
+		select {
+		case line := <-lines:
+			if strings.Contains(string(line), "after reopens") {
+				return
+			}
+		case <-deadline:
+			t.Fatal("Expected the message logged after the reopens to arrive")
+		}
+	}
+}
+
+func TestCloseIsIdempotent(t *testing.T) {
+	l, _ := listenTCP(t)
+	tcp, err := DialTCP(l.Addr().String(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	udp, err := DialUDP(listenUDP(t).LocalAddr().String(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, w := range map[string]Writer{"tcp": tcp, "udp": udp} {
+		if err := w.Close(); err != nil {
+			t.Fatalf("Expected the first %s Close to succeed, got %s", name, err)
+		}
+		if err := w.Close(); err != nil {
+			t.Errorf("Expected a second %s Close to return nil, got %s", name, err)
+		}
+	}
+}
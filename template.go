@@ -0,0 +1,53 @@
+package logopher
+
+import (
+	"bytes"
+	"encoding/json"
+	"text/template"
+	"time"
+)
+
+// Template renders a message into a payload, for pipelines whose schema doesn't
+// match the default envelope. It is given the message, the writer's host, the
+// current time in the writer's Location, and any structured fields, including
+// the level added by the leveled helpers. The Terminator is added by the
+// writer, and must not be included. Nor may the payload contain any other
+// newline, or the line codec will split it into several events, so escape any
+// value which may contain one, such as a multi-line message.
+type Template func(msg, host string, ts time.Time, fields map[string]interface{}) ([]byte, error)
+
+// TemplateData is what a text/template passed to NewTextTemplate is executed
+// against
+type TemplateData struct {
+	Message   string
+	Host      string
+	Timestamp time.Time
+	Fields    map[string]interface{}
+}
+
+// NewTextTemplate parses text as a text/template and returns a Template which
+// executes it against a TemplateData. Since text/template knows nothing about
+// JSON, the template can use the json function to render a quoted and escaped
+// value, for example:
+//
+//	{"@timestamp":{{json .Timestamp}},"@version":"1","msg":{{json .Message}}}
+func NewTextTemplate(text string) (Template, error) {
+	t, err := template.New("logopher").Funcs(template.FuncMap{"json": templateJSON}).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return func(msg, host string, ts time.Time, fields map[string]interface{}) ([]byte, error) {
+		var buf bytes.Buffer
+		data := TemplateData{Message: msg, Host: host, Timestamp: ts, Fields: fields}
+		if err := t.Execute(&buf, data); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}, nil
+}
+
+// templateJSON renders v as JSON, for use inside a text/template
+func templateJSON(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	return string(data), err
+}
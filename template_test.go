@@ -0,0 +1,69 @@
+package logopher
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestTemplateFunc(t *testing.T) {
+	conn := &fakeConn{}
+	w, _ := newFakeWriter(t, conn)
+	w.Host = "box-1"
+	w.Template = func(msg, host string, ts time.Time, fields map[string]interface{}) ([]byte, error) {
+		return []byte(fmt.Sprintf("%s|%s|%v", host, msg, fields["level"])), nil
+	}
+
+	if _, err := w.Warn("custom"); err != nil {
+		t.Fatal(err)
+	}
+	if got := string(conn.Writes()[0]); got != "box-1|custom|warn\n" {
+		t.Errorf("Unexpected payload %q", got)
+	}
+}
+
+func TestTextTemplate(t *testing.T) {
+	tmpl, err := NewTextTemplate(`{"time":{{json .Timestamp}},"@version":"1","msg":{{json .Message}},"source":{{json .Host}}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := &fakeConn{}
+	w, _ := newFakeWriter(t, conn)
+	w.Host = "box-1"
+	w.Template = tmpl
+
+	if _, err := w.Log(`needs "escaping"`); err != nil {
+		t.Fatal(err)
+	}
+	var event map[string]string
+	if err := json.Unmarshal(conn.Writes()[0], &event); err != nil {
+		t.Fatal(err)
+	}
+	if event["msg"] != `needs "escaping"` || event["source"] != "box-1" || event["@version"] != "1" {
+		t.Errorf("Unexpected event %v", event)
+	}
+	if _, err := time.Parse(time.RFC3339Nano, event["time"]); err != nil {
+		t.Errorf("Expected an RFC3339 time, got %q", event["time"])
+	}
+}
+
+func TestTextTemplateErrors(t *testing.T) {
+	if _, err := NewTextTemplate("{{.Message"); err == nil {
+		t.Error("Expected a parse error")
+	}
+
+	tmpl, err := NewTextTemplate("{{.Missing}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := &fakeConn{}
+	w, _ := newFakeWriter(t, conn)
+	w.Template = tmpl
+	if _, err := w.Log("hi"); err == nil {
+		t.Error("Expected an execution error")
+	}
+	if len(conn.Writes()) != 0 {
+		t.Error("Expected nothing to be written")
+	}
+}
@@ -0,0 +1,64 @@
+package logopher
+
+import (
+	"crypto/tls"
+	"net"
+)
+
+var _ FieldLogger = (*TLSWriter)(nil)
+
+// TLSWriter represents an abstraction over a tls encrypted tcp connection and
+// error handling for writing data to logstash
+type TLSWriter struct {
+	baseWriter
+}
+
+// DialTLS creates a new TLSWriter. A nil config uses the system root CAs and
+// verifies the server against the host in address. Custom root CAs and client
+// certificates can be supplied through the config.
+func DialTLS(address string, config *tls.Config, enableLogging bool) (*TLSWriter, error) {
+	return NewTLS(address, config, WithLogging(enableLogging))
+}
+
+// NewTLS is DialTLS, configured by opts the same way New configures a
+// UDPWriter
+func NewTLS(address string, config *tls.Config, opts ...Option) (*TLSWriter, error) {
+	address, err := normalizeAddress(address)
+	if err != nil {
+		return nil, err
+	}
+	writer := &TLSWriter{
+		baseWriter: newBaseWriter("tcp", address, false),
+	}
+	writer.upgrade = tlsUpgrade(address, config)
+	for _, opt := range opts {
+		opt(&writer.baseWriter)
+	}
+
+	if err := writer.open(); err != nil {
+		return nil, err
+	}
+	return writer, nil
+}
+
+// tlsUpgrade returns a function which wraps a freshly dialed connection in tls
+// and completes the handshake up front, so that both DialTLS and Reopen surface
+// certificate problems immediately instead of on the first write
+func tlsUpgrade(address string, config *tls.Config) func(net.Conn) (net.Conn, error) {
+	if config == nil {
+		config = &tls.Config{}
+	}
+	if config.ServerName == "" {
+		// Verify against the host we meant to reach, as tls.Dial would
+		config = config.Clone()
+		config.ServerName, _, _ = net.SplitHostPort(address)
+	}
+	return func(conn net.Conn) (net.Conn, error) {
+		tlsConn := tls.Client(conn, config)
+		if err := tlsConn.Handshake(); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return tlsConn, nil
+	}
+}
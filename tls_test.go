@@ -0,0 +1,148 @@
+package logopher
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+)
+
+// selfSignedCert generates a certificate for 127.0.0.1, along with a pool that
+// trusts it
+func selfSignedCert(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{Organization: []string{"Logopher"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, pool
+}
+
+// listenTLS opens a local TLS listener to stand in for LogStash. Every line
+// received is sent down the returned channel.
+func listenTLS(t *testing.T, cert tls.Certificate) (net.Listener, <-chan []byte) {
+	l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	lines := make(chan []byte, 100)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				for {
+					line, err := r.ReadBytes('\n')
+					if err != nil {
+						return
+					}
+					lines <- line
+				}
+			}()
+		}
+	}()
+	return l, lines
+}
+
+func TestTLSLogAndReopen(t *testing.T) {
+	cert, pool := selfSignedCert(t)
+	l, lines := listenTLS(t, cert)
+	w, err := DialTLS(l.Addr().String(), &tls.Config{RootCAs: pool}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	for i, msg := range []string{"before reopen", "after reopen"} {
+		if i == 1 {
+			if err := w.Reopen(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := w.Log(msg); err != nil {
+			t.Fatal(err)
+		}
+		var event map[string]string
+		if err := json.Unmarshal(readLine(t, lines), &event); err != nil {
+			t.Fatal(err)
+		}
+		if event["message"] != msg {
+			t.Errorf("Expected message %q, got %q", msg, event["message"])
+		}
+	}
+}
+
+func TestTLSUntrustedCertificate(t *testing.T) {
+	cert, _ := selfSignedCert(t)
+	l, _ := listenTLS(t, cert)
+	if _, err := DialTLS(l.Addr().String(), nil, false); err == nil {
+		t.Error("Expected the handshake to fail against an untrusted certificate")
+	}
+}
+
+func TestTLSWriterCloseTimeout(t *testing.T) {
+	cert, pool := selfSignedCert(t)
+	client, server := net.Pipe()
+	t.Cleanup(func() { server.Close() })
+	go func() {
+		// Complete the handshake, then stop reading, so the client's
+		// close_notify alert can never be written
+		tls.Server(server, &tls.Config{Certificates: []tls.Certificate{cert}}).Handshake()
+	}()
+
+	w := &TLSWriter{baseWriter: newBaseWriter("tcp", "127.0.0.1:5000", false)}
+	w.Dialer = func(network, address string) (net.Conn, error) { return client, nil }
+	w.upgrade = tlsUpgrade(w.address, &tls.Config{RootCAs: pool})
+	if err := w.open(); err != nil {
+		t.Fatal(err)
+	}
+	w.CloseTimeout = 100 * time.Millisecond
+
+	start := time.Now()
+	err := w.Close()
+	if !errors.Is(err, ErrCloseTimeout) {
+		t.Errorf("Expected ErrCloseTimeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected Close to give up after the timeout, took %s", elapsed)
+	}
+	if _, err := client.Write([]byte("x")); !errors.Is(err, io.ErrClosedPipe) {
+		t.Errorf("Expected the underlying connection to be closed, got %v", err)
+	}
+}
@@ -0,0 +1,25 @@
+package logopher
+
+import "unicode/utf8"
+
+// TruncationMarker ends a message cut short by MaxMessageBytes
+const TruncationMarker = "…"
+
+// truncate cuts msg down to MaxMessageBytes, marker included, without splitting
+// a multi-byte character
+func (u *baseWriter) truncate(msg string) string {
+	limit := u.MaxMessageBytes
+	if limit <= 0 || len(msg) <= limit {
+		return msg
+	}
+	u.logf("Truncating a %d byte message to the limit of %d bytes", len(msg), limit)
+	marker := TruncationMarker
+	if limit < len(marker) {
+		marker = ""
+	}
+	cut := limit - len(marker)
+	for cut > 0 && !utf8.RuneStart(msg[cut]) {
+		cut--
+	}
+	return msg[:cut] + marker
+}
@@ -0,0 +1,55 @@
+package logopher
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestMaxMessageBytes(t *testing.T) {
+	conn := &fakeConn{}
+	w, _ := newFakeWriter(t, conn)
+	w.MaxMessageBytes = 16
+	logger := &captureLogger{}
+	w.Logger = logger
+
+	long := strings.Repeat("a", 100)
+	for _, log := range []func(){
+		func() { w.Log(long) },
+		func() { w.LogFields(long, map[string]interface{}{"k": "v"}) },
+	} {
+		log()
+		writes := conn.Writes()
+		if !json.Valid(writes[len(writes)-1]) {
+			t.Fatalf("Expected valid JSON, got %s", writes[len(writes)-1])
+		}
+		msg := lastEvent(t, conn)["message"].(string)
+		if msg != strings.Repeat("a", 16-len(TruncationMarker))+TruncationMarker {
+			t.Errorf("Expected the message cut to 16 bytes with a marker, got %q", msg)
+		}
+	}
+	if len(logger.lines) != 2 || !strings.Contains(logger.lines[0], "Truncating a 100 byte message") {
+		t.Errorf("Expected a warning for each truncation, got %q", logger.lines)
+	}
+
+	w.Log("short")
+	if msg := lastEvent(t, conn)["message"]; msg != "short" {
+		t.Errorf("Expected a short message to be left alone, got %q", msg)
+	}
+}
+
+func TestMaxMessageBytesKeepsCharactersWhole(t *testing.T) {
+	w, _ := newFakeWriter(t, &fakeConn{})
+	// Each character is three bytes, so a cut at 10 bytes, less the marker,
+	// would otherwise land in the middle of the third
+	w.MaxMessageBytes = 10
+	got := w.truncate(strings.Repeat("日", 10))
+	if got != "日日"+TruncationMarker {
+		t.Errorf("Expected two whole characters and the marker, got %q", got)
+	}
+
+	w.MaxMessageBytes = 2
+	if got := w.truncate("abcdef"); got != "ab" {
+		t.Errorf("Expected a limit shorter than the marker to cut without it, got %q", got)
+	}
+}
@@ -0,0 +1,37 @@
+package logopher
+
+import "fmt"
+
+var _ FieldLogger = (*UnixWriter)(nil)
+
+// UnixWriter represents an abstraction over a unix domain socket and error
+// handling for writing data to a logstash running on the same host, which
+// avoids the network stack entirely
+type UnixWriter struct {
+	baseWriter
+}
+
+// DialUnix creates a new UnixWriter connected to the stream socket at path
+func DialUnix(path string, enableLogging bool) (*UnixWriter, error) {
+	return DialUnixNetwork("unix", path, enableLogging)
+}
+
+// DialUnixNetwork creates a new UnixWriter on a specific network, which must be
+// "unix" for a stream socket or "unixgram" for a datagram socket. A unixgram
+// socket never delivers part of a message, but unlike udp it won't silently
+// drop one either: a write fails if the receiver's buffer is full.
+func DialUnixNetwork(network, path string, enableLogging bool) (*UnixWriter, error) {
+	switch network {
+	case "unix", "unixgram":
+	default:
+		return nil, fmt.Errorf("logopher: unsupported unix network %q", network)
+	}
+	writer := &UnixWriter{
+		baseWriter: newBaseWriter(network, path, enableLogging),
+	}
+
+	if err := writer.open(); err != nil {
+		return nil, err
+	}
+	return writer, nil
+}
@@ -0,0 +1,91 @@
+package logopher
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDialUnix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logstash.sock")
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	w, err := DialUnix(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if _, err := w.Log("over a unix socket"); err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	var event map[string]interface{}
+	if err := json.Unmarshal(line, &event); err != nil {
+		t.Fatal(err)
+	}
+	if event["message"] != "over a unix socket" {
+		t.Errorf("Expected message %q, got %v", "over a unix socket", event["message"])
+	}
+}
+
+func TestDialUnixgram(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logstash.sock")
+	l, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	w, err := DialUnixNetwork("unixgram", path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	for _, msg := range []string{"first", "second"} {
+		if _, err := w.Log(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	buf := make([]byte, 65536)
+	l.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for _, expected := range []string{"first", "second"} {
+		n, err := l.Read(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var event map[string]interface{}
+		if err := json.Unmarshal(buf[:n], &event); err != nil {
+			t.Fatal(err)
+		}
+		if event["message"] != expected {
+			t.Errorf("Expected message %q, got %v", expected, event["message"])
+		}
+	}
+}
+
+func TestDialUnixErrors(t *testing.T) {
+	if _, err := DialUnixNetwork("unixpacket", "/tmp/logstash.sock", false); err == nil {
+		t.Error("Expected an unsupported network to be rejected")
+	}
+	if _, err := DialUnix(filepath.Join(t.TempDir(), "missing.sock"), false); err == nil {
+		t.Error("Expected dialing a missing socket to fail")
+	}
+}